@@ -252,36 +252,3 @@ func (p *BaseProcess) Auditf(msg string, parts ...interface{}) {
 func (p *BaseProcess) Audit(msg interface{}) {
 	Audit.Printf("[Process:%s] %s"+"\n", p.Name(), msg)
 }
-
-func (p *BaseProcess) receiveOnInPorts() (ips map[string]*FileIP, inPortsOpen bool) {
-	inPortsOpen = true
-	ips = make(map[string]*FileIP)
-	// Read input IPs on in-ports and set up path mappings
-	for inpName, inPort := range p.InPorts() {
-		Debug.Printf("[Process %s]: Receieving on inPort (%s) ...", p.name, inpName)
-		ip, open := <-inPort.Chan
-		if !open {
-			inPortsOpen = false
-			continue
-		}
-		Debug.Printf("[Process %s]: Got ip (%s) ...", p.name, ip.Path())
-		ips[inpName] = ip
-	}
-	return
-}
-
-func (p *BaseProcess) receiveOnInParamPorts() (params map[string]string, paramPortsOpen bool) {
-	paramPortsOpen = true
-	params = make(map[string]string)
-	// Read input IPs on in-ports and set up path mappings
-	for pname, pport := range p.InParamPorts() {
-		pval, open := <-pport.Chan
-		if !open {
-			paramPortsOpen = false
-			continue
-		}
-		Debug.Printf("[Process %s]: Got param %s ...", p.name, pval)
-		params[pname] = pval
-	}
-	return
-}
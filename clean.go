@@ -0,0 +1,135 @@
+package scipipe
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Cleaning up after crashed runs
+// ----------------------------------------------------------------------------
+
+// StaleTempFiles contains the temporary folders (starting with _scipipe_tmp)
+// and FIFO files (ending with .fifo) left behind by crashed workflow runs
+type StaleTempFiles struct {
+	TempDirs  []string
+	FifoFiles []string
+	// dir is the directory that the files were found in
+	dir string
+}
+
+// Empty returns true if no stale temporary folders or FIFO files were found
+func (s *StaleTempFiles) Empty() bool {
+	return len(s.TempDirs) == 0 && len(s.FifoFiles) == 0
+}
+
+// FindStaleTempFiles finds temporary folders directly under dir, which is
+// where they are created by running workflows, and FIFO files anywhere under
+// dir. Since these are only stale if no workflow is currently running in dir,
+// an error is returned if a workflow holds the lock file in dir (See
+// LockFileName).
+func FindStaleTempFiles(dir string) (*StaleTempFiles, error) {
+	if f, holder, err := lockDir(dir, "searching for stale temporary files"); err == errAlreadyLocked {
+		return nil, errWorkflowRunningInDir(dir, holder)
+	} else if err == nil {
+		unlockDir(f)
+	}
+
+	stale := &StaleTempFiles{
+		TempDirs:  []string{},
+		FifoFiles: []string{},
+		dir:       dir,
+	}
+	err := filepath.Walk(dir, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.IsDir() {
+			if path != dir && strings.HasPrefix(fileInfo.Name(), tempDirPrefix+".") && filepath.Dir(path) == filepath.Clean(dir) {
+				stale.TempDirs = append(stale.TempDirs, path)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(fileInfo.Name(), ".fifo") && fileInfo.Mode()&os.ModeNamedPipe != 0 {
+			stale.FifoFiles = append(stale.FifoFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not search for stale temporary files in %s: %v", dir, err)
+	}
+	sort.Strings(stale.TempDirs)
+	sort.Strings(stale.FifoFiles)
+	return stale, nil
+}
+
+// Remove removes the stale temporary folders and FIFO files. If quarantineDir
+// is not empty, the temporary folders, which may contain outputs of
+// unfinished tasks, are moved into quarantineDir instead of being deleted.
+// The lock file in the directory the files were found in is held while
+// removing, so that no workflow can start running there meanwhile, and
+// nothing is removed if a workflow is already running there.
+func (s *StaleTempFiles) Remove(quarantineDir string) error {
+	dir := s.dir
+	if dir == "" {
+		dir = "."
+	}
+	f, holder, err := lockDir(dir, "cleaning stale temporary files")
+	if err == errAlreadyLocked {
+		return errWorkflowRunningInDir(dir, holder)
+	} else if err != nil {
+		return err
+	}
+	defer unlockDir(f)
+
+	if quarantineDir != "" && len(s.TempDirs) > 0 {
+		err := os.MkdirAll(quarantineDir, 0777)
+		if err != nil {
+			return fmt.Errorf("Could not create quarantine directory %s: %v", quarantineDir, err)
+		}
+	}
+	for _, tempDir := range s.TempDirs {
+		if quarantineDir != "" {
+			newPath := filepath.Join(quarantineDir, filepath.Base(tempDir))
+			Info.Printf("Moving temporary folder to quarantine: %s -> %s\n", tempDir, newPath)
+			err := os.Rename(tempDir, newPath)
+			if err != nil {
+				return fmt.Errorf("Could not move temporary folder %s to quarantine: %v", tempDir, err)
+			}
+			continue
+		}
+		Info.Printf("Removing temporary folder: %s\n", tempDir)
+		err := os.RemoveAll(tempDir)
+		if err != nil {
+			return fmt.Errorf("Could not remove temporary folder %s: %v", tempDir, err)
+		}
+	}
+	for _, fifoFile := range s.FifoFiles {
+		Info.Printf("Removing FIFO file: %s\n", fifoFile)
+		err := os.Remove(fifoFile)
+		if err != nil {
+			return fmt.Errorf("Could not remove FIFO file %s: %v", fifoFile, err)
+		}
+	}
+	return nil
+}
+
+func errWorkflowRunningInDir(dir string, holder string) error {
+	return fmt.Errorf("Not cleaning %s, since a workflow is running there (lock file %s is held by: %s)", dir, LockFileName, holder)
+}
+
+// CleanStaleTempFiles finds and removes temporary folders and FIFO files left
+// behind by crashed workflow runs in dir (See FindStaleTempFiles and
+// StaleTempFiles.Remove). It can be called before running a workflow, to make
+// the workflow clean up after previous crashed runs on startup.
+func CleanStaleTempFiles(dir string, quarantineDir string) error {
+	stale, err := FindStaleTempFiles(dir)
+	if err != nil {
+		return err
+	}
+	return stale.Remove(quarantineDir)
+}
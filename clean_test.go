@@ -0,0 +1,112 @@
+package scipipe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFindAndCleanStaleTempFiles(t *testing.T) {
+	initTestLogs()
+	dir := createStaleTempFiles(t, ".tmp/clean_test")
+	defer os.RemoveAll(".tmp")
+
+	stale, err := FindStaleTempFiles(dir)
+	assertNil(t, err)
+	assertEqualValues(t, []string{filepath.Join(dir, "_scipipe_tmp.foo.abc123")}, stale.TempDirs, "Wrong temp dirs found")
+	assertEqualValues(t, []string{filepath.Join(dir, "sub", "out.txt.fifo")}, stale.FifoFiles, "Wrong FIFO files found")
+
+	err = CleanStaleTempFiles(dir, "")
+	assertNil(t, err)
+	for _, path := range append(stale.TempDirs, stale.FifoFiles...) {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Stale file was not removed: %s", path)
+		}
+	}
+	for _, path := range []string{filepath.Join(dir, "sub", "other.fifo"), filepath.Join(dir, "sub", "_scipipe_tmp.nested")} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Errorf("File that should not be cleaned was removed: %s", path)
+		}
+	}
+}
+
+func TestCleanStaleTempFilesQuarantine(t *testing.T) {
+	initTestLogs()
+	dir := createStaleTempFiles(t, ".tmp/clean_test")
+	defer os.RemoveAll(".tmp")
+
+	quarantineDir := ".tmp/quarantine"
+	err := CleanStaleTempFiles(dir, quarantineDir)
+	assertNil(t, err)
+
+	dat, err := ioutil.ReadFile(filepath.Join(quarantineDir, "_scipipe_tmp.foo.abc123", "out.txt"))
+	assertNil(t, err, "Output in temp dir was not moved to quarantine")
+	assertEqualValues(t, "unfinished", string(dat))
+	if _, err := os.Stat(filepath.Join(dir, "sub", "out.txt.fifo")); !os.IsNotExist(err) {
+		t.Error("FIFO file was not removed")
+	}
+}
+
+func TestCleanStaleTempFilesWhileWorkflowRunning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Locking is not supported on Windows")
+	}
+	initTestLogs()
+	dir := createStaleTempFiles(t, ".tmp/clean_test")
+	defer os.RemoveAll(".tmp")
+	tempDir := filepath.Join(dir, "_scipipe_tmp.foo.abc123")
+
+	stale, err := FindStaleTempFiles(dir)
+	assertNil(t, err)
+
+	// Simulate a workflow starting to run in dir
+	f, _, err := lockDir(dir, "running workflow wf")
+	assertNil(t, err, "Could not take lock")
+
+	for _, err := range []error{CleanStaleTempFiles(dir, ""), stale.Remove("")} {
+		if err == nil {
+			t.Fatal("Expected error when cleaning while a workflow is running, but got none")
+		}
+		expected := fmt.Sprintf("PID %d", os.Getpid())
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain '%s', but got: %s", expected, err.Error())
+		}
+	}
+	if _, err := os.Stat(tempDir); os.IsNotExist(err) {
+		t.Error("Temp dir of running workflow was removed")
+	}
+
+	unlockDir(f)
+	err = stale.Remove("")
+	assertNil(t, err, "Could not clean after the lock was released")
+	if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+		t.Error("Temp dir was not removed after the lock was released")
+	}
+}
+
+// createStaleTempFiles creates a temp dir and a FIFO file like the ones left
+// by a crashed run, as well as a regular file named .fifo and a nested folder
+// named like a temp dir, that should not be touched
+func createStaleTempFiles(t *testing.T, dir string) string {
+	tempDir := filepath.Join(dir, "_scipipe_tmp.foo.abc123")
+	for _, d := range []string{tempDir, filepath.Join(dir, "sub", "_scipipe_tmp.nested")} {
+		if err := os.MkdirAll(d, 0777); err != nil {
+			t.Fatal("Could not create dir:", d)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "out.txt"), []byte("unfinished"), 0644); err != nil {
+		t.Fatal("Could not write file in temp dir")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "other.fifo"), []byte("regular file"), 0644); err != nil {
+		t.Fatal("Could not write regular .fifo file")
+	}
+	if out, err := exec.Command("mkfifo", filepath.Join(dir, "sub", "out.txt.fifo")).CombinedOutput(); err != nil {
+		t.Fatal("Could not create FIFO file:", string(out))
+	}
+	return dir
+}
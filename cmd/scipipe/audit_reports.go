@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
@@ -186,6 +187,23 @@ func auditInfoToTeX(inFilePath string, outFilePath string, flatten bool) error {
 	return nil
 }
 
+func auditInfoToPROV(inFilePath string, outFilePath string) error {
+	if _, err := os.Stat(inFilePath); err != nil {
+		return errWrap(err, "Could not find audit file: "+inFilePath)
+	}
+	auditInfo := scipipe.UnmarshalAuditInfoJSONFile(inFilePath)
+	provJSON, err := scipipe.NewPROVDocument(auditInfo).JSON()
+	if err != nil {
+		return errWrap(err, "Could not convert audit info to PROV-JSON")
+	}
+	err = ioutil.WriteFile(outFilePath, append(provJSON, '\n'), 0644)
+	if err != nil {
+		return errWrap(err, "Could not write file: "+outFilePath)
+	}
+	fmt.Println("Wrote PROV-JSON file to: " + outFilePath)
+	return nil
+}
+
 func extractAuditInfosByID(auditInfo *scipipe.AuditInfo) (auditInfosByID map[string]*scipipe.AuditInfo) {
 	auditInfosByID = make(map[string]*scipipe.AuditInfo)
 	auditInfosByID[auditInfo.ID] = auditInfo
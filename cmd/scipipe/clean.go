@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/scipipe/scipipe"
+)
+
+// cleanStaleTempFiles finds temporary folders and FIFO files left behind by
+// crashed workflow runs, lists them, and removes them after the user has
+// confirmed on in (unless the -y flag is given). args are the arguments
+// following the clean command.
+func cleanStaleTempFiles(args []string, in io.Reader) error {
+	flags := flag.NewFlagSet("clean", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	yes := flags.Bool("y", false, "Remove files without asking for confirmation")
+	quarantineDir := flags.String("quarantine", "", "Move temporary folders into this directory instead of deleting them")
+	err := flags.Parse(args)
+	if err != nil {
+		return errWrap(err, "Could not parse flags for the clean command")
+	}
+	if flags.NArg() > 1 {
+		return errors.New("Extra arguments found: " + strings.Join(flags.Args()[1:], " "))
+	}
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	stale, err := scipipe.FindStaleTempFiles(dir)
+	if err != nil {
+		return err
+	}
+	if stale.Empty() {
+		Info.Println("No stale temporary folders or FIFO files found in:", dir)
+		return nil
+	}
+
+	for _, tempDir := range stale.TempDirs {
+		if *quarantineDir != "" {
+			Info.Printf("Would move to quarantine (%s): %s\n", *quarantineDir, tempDir)
+		} else {
+			Info.Println("Would remove:", tempDir)
+		}
+	}
+	for _, fifoFile := range stale.FifoFiles {
+		Info.Println("Would remove:", fifoFile)
+	}
+
+	if !*yes {
+		fmt.Printf("Proceed with cleaning %d files/folders? [y/N]: ", len(stale.TempDirs)+len(stale.FifoFiles))
+		answer, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return errWrap(err, "Could not read confirmation")
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			Info.Println("Aborted. Nothing was removed.")
+			return nil
+		}
+	}
+
+	// The files to remove are already listed above, so only log errors from
+	// the scipipe library
+	scipipe.InitLogError()
+	err = stale.Remove(*quarantineDir)
+	if err != nil {
+		return errWrap(err, "Could not clean stale temporary files")
+	}
+	Info.Println("Cleaned stale temporary files in:", dir)
+	return nil
+}
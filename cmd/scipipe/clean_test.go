@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCleanCmd(t *testing.T) {
+	initLogsTest()
+	tempDir := ".tmp/_scipipe_tmp.foo.abc123"
+	os.MkdirAll(tempDir, 0744)
+	defer os.RemoveAll(".tmp")
+
+	err := cleanStaleTempFiles([]string{".tmp"}, strings.NewReader("n\n"))
+	if err != nil {
+		t.Fatal("Clean command failed:", err.Error())
+	}
+	if _, err := os.Stat(tempDir); os.IsNotExist(err) {
+		t.Error("Temp dir was removed although not confirmed")
+	}
+
+	err = cleanStaleTempFiles([]string{".tmp"}, strings.NewReader("y\n"))
+	if err != nil {
+		t.Fatal("Clean command failed:", err.Error())
+	}
+	if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+		t.Error("Temp dir was not removed although confirmed")
+	}
+
+	os.MkdirAll(tempDir, 0744)
+	err = cleanStaleTempFiles([]string{"-y", "-quarantine", ".tmp/quarantine", ".tmp"}, strings.NewReader(""))
+	if err != nil {
+		t.Fatal("Clean command failed:", err.Error())
+	}
+	if _, err := os.Stat(".tmp/quarantine/_scipipe_tmp.foo.abc123"); os.IsNotExist(err) {
+		t.Error("Temp dir was not moved to quarantine")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scipipe/scipipe"
+)
+
+// showHistory lists the runs in the run history, shows the details of one
+// run, or finds the runs that produced a given file, depending on args, which
+// are the arguments following the history command:
+//
+//	history [-file <history file>] [-n <number of runs>]
+//	history [-file <history file>] show <run id>
+//	history [-file <history file>] find <output file>
+func showHistory(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	historyFile := flags.String("file", scipipe.HistoryFile, "The history file to read")
+	numRuns := flags.Int("n", 20, "The number of runs to list (0 for all)")
+	err := flags.Parse(args)
+	if err != nil {
+		return errWrap(err, "Could not parse flags for the history command")
+	}
+
+	recs, err := scipipe.ReadHistory(*historyFile)
+	if err != nil {
+		return errWrapf(err, "Could not read run history from %s", *historyFile)
+	}
+
+	switch flags.Arg(0) {
+	case "":
+		if *numRuns > 0 && len(recs) > *numRuns {
+			recs = recs[len(recs)-*numRuns:]
+		}
+		printRunList(recs)
+	case "show":
+		if flags.NArg() != 2 {
+			return errors.New("Specify one run ID to show")
+		}
+		for _, rec := range recs {
+			if rec.RunID == flags.Arg(1) {
+				printRunDetails(rec)
+				return nil
+			}
+		}
+		return errors.New("No run found with ID: " + flags.Arg(1))
+	case "find":
+		if flags.NArg() != 2 {
+			return errors.New("Specify one output file to find")
+		}
+		found := findRunsByOutput(recs, flags.Arg(1))
+		if len(found) == 0 {
+			Info.Println("No run found that produced:", flags.Arg(1))
+			return nil
+		}
+		printRunList(found)
+	default:
+		return errors.New("Unknown history sub-command: " + flags.Arg(0))
+	}
+	return nil
+}
+
+// findRunsByOutput returns the runs among recs that produced the file at
+// path
+func findRunsByOutput(recs []*scipipe.RunRecord, path string) []*scipipe.RunRecord {
+	path = filepath.Clean(path)
+	found := []*scipipe.RunRecord{}
+	for _, rec := range recs {
+		for _, output := range rec.Outputs {
+			if filepath.Clean(output) == path {
+				found = append(found, rec)
+				break
+			}
+		}
+	}
+	return found
+}
+
+func printRunList(recs []*scipipe.RunRecord) {
+	if len(recs) == 0 {
+		Info.Println("No runs found")
+		return
+	}
+	sb := &strings.Builder{}
+	tw := tabwriter.NewWriter(sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN ID\tWORKFLOW\tSTARTED\tDURATION\tSTATUS\tRUN\tSKIPPED\tFAILED")
+	for _, rec := range recs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\n", rec.RunID, rec.Workflow, rec.StartTime.Format("2006-01-02 15:04:05"), rec.EndTime.Sub(rec.StartTime).Round(time.Second), rec.Status, rec.TasksRun, rec.TasksSkipped, rec.TasksFailed)
+	}
+	tw.Flush()
+	Info.Print(sb.String())
+}
+
+func printRunDetails(rec *scipipe.RunRecord) {
+	sb := &strings.Builder{}
+	tw := tabwriter.NewWriter(sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Run ID:\t%s\n", rec.RunID)
+	fmt.Fprintf(tw, "Workflow:\t%s\n", rec.Workflow)
+	fmt.Fprintf(tw, "Status:\t%s\n", rec.Status)
+	fmt.Fprintf(tw, "Started:\t%s\n", rec.StartTime.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(tw, "Ended:\t%s\n", rec.EndTime.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(tw, "Duration:\t%s\n", rec.EndTime.Sub(rec.StartTime).Round(time.Second))
+	fmt.Fprintf(tw, "Tasks:\t%d run, %d skipped, %d failed\n", rec.TasksRun, rec.TasksSkipped, rec.TasksFailed)
+	fmt.Fprintf(tw, "Log file:\t%s\n", rec.LogFile)
+	tw.Flush()
+	fmt.Fprintf(sb, "Outputs (%d):\n", len(rec.Outputs))
+	for _, output := range rec.Outputs {
+		fmt.Fprintf(sb, "  %s\n", output)
+	}
+	Info.Print(sb.String())
+}
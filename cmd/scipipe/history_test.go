@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scipipe/scipipe"
+)
+
+func TestHistoryCmd(t *testing.T) {
+	historyFile := ".tmp/history.jsonl"
+	defer os.RemoveAll(".tmp")
+
+	startTime := time.Date(2018, 7, 17, 14, 0, 0, 0, time.UTC)
+	for _, rec := range []*scipipe.RunRecord{
+		{RunID: "run1", Workflow: "wf1", StartTime: startTime, EndTime: startTime.Add(time.Minute), Status: scipipe.RunStatusFinished, TasksRun: 2, Outputs: []string{"data/a.txt", "data/b.txt"}},
+		{RunID: "run2", Workflow: "wf2", StartTime: startTime.Add(time.Hour), EndTime: startTime.Add(2 * time.Hour), Status: scipipe.RunStatusFailed, TasksRun: 1, TasksFailed: 1, LogFile: "log/wf2.log", Outputs: []string{"data/c.txt"}},
+	} {
+		err := scipipe.AppendRunRecord(historyFile, rec)
+		if err != nil {
+			t.Fatalf("Could not write run record: %v", err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	Info = log.New(out, "", 0)
+	defer initLogsTest()
+
+	for _, tc := range []struct {
+		args       []string
+		expected   []string
+		unexpected []string
+	}{
+		{[]string{"-file", historyFile}, []string{"run1", "run2", "failed"}, []string{}},
+		{[]string{"-file", historyFile, "-n", "1"}, []string{"run2"}, []string{"run1"}},
+		{[]string{"-file", historyFile, "show", "run2"}, []string{"Workflow:  wf2", "log/wf2.log", "data/c.txt", "1 run, 0 skipped, 1 failed"}, []string{"run1"}},
+		{[]string{"-file", historyFile, "find", "./data/b.txt"}, []string{"run1"}, []string{"run2"}},
+	} {
+		out.Reset()
+		err := showHistory(tc.args)
+		if err != nil {
+			t.Fatalf("History command failed for args %v: %v", tc.args, err)
+		}
+		for _, s := range tc.expected {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("Output for args %v does not contain %q:\n%s", tc.args, s, out.String())
+			}
+		}
+		for _, s := range tc.unexpected {
+			if strings.Contains(out.String(), s) {
+				t.Errorf("Output for args %v unexpectedly contains %q:\n%s", tc.args, s, out.String())
+			}
+		}
+	}
+
+	err := showHistory([]string{"-file", historyFile, "show", "nonexisting"})
+	if err == nil {
+		t.Error("Expected error when showing non-existing run")
+	}
+}
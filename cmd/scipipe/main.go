@@ -40,6 +40,24 @@ func parseFlags(args []string) error {
 			return errors.New("No infile specified")
 		}
 		writeNewWorkflowFile(args[1])
+	case "run":
+		if len(args) < 2 {
+			return errors.New("No workflow file specified")
+		}
+		err := runWorkflowFile(args[1])
+		if err != nil {
+			return errWrap(err, "Could not run workflow")
+		}
+	case "clean":
+		err := cleanStaleTempFiles(args[1:], os.Stdin)
+		if err != nil {
+			return errWrap(err, "Could not clean stale temporary files")
+		}
+	case "history":
+		err := showHistory(args[1:])
+		if err != nil {
+			return errWrap(err, "Could not show run history")
+		}
 	case "audit2html":
 		inFile, outFile, err := parseArgsAudit2X(args, "html")
 		if err != nil {
@@ -67,6 +85,20 @@ func parseFlags(args []string) error {
 		if err != nil {
 			return errWrap(err, "Could not convert Audit file to Bash")
 		}
+	case "audit2prov":
+		inFile, outFile, err := parseArgsAudit2X(args, "prov.json")
+		if err != nil {
+			return errWrap(err, "Could not parse filenames from arguments")
+		}
+		err = auditInfoToPROV(inFile, outFile)
+		if err != nil {
+			return errWrap(err, "Could not convert Audit file to PROV-JSON")
+		}
+	case "rocrate":
+		err := createROCrate(args[1:])
+		if err != nil {
+			return errWrap(err, "Could not create RO-Crate")
+		}
 	default:
 		return errors.New("Unknown command: " + cmd)
 	}
@@ -134,9 +166,14 @@ $ scipipe <command> [command options]
 
 Available commands:
 $ scipipe new <filename.go>
+$ scipipe run <workflow.yaml|workflow.json>
+$ scipipe clean [-y] [-quarantine <dir>] [<dir>]
+$ scipipe history [-file <history file>] [-n <runs>] [show <run id> | find <file>]
 $ scipipe audit2html <infile.audit.json> [<outfile.html>]
 $ scipipe audit2tex <infile.audit.json> [<outfile.tex>]
 $ scipipe audit2bash <infile.audit.json> [<outfile.sh>]
+$ scipipe audit2prov <infile.audit.json> [<outfile.prov.json>]
+$ scipipe rocrate [-o <crate dir|crate.zip>] [-max-size <bytes>] <outfile>
 ________________________________________________________________________
 `, scipipe.Version)
 	}
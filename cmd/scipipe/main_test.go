@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -30,6 +31,40 @@ func TestNewCmd(t *testing.T) {
 	os.RemoveAll(".tmp")
 }
 
+func TestAudit2PROVCmd(t *testing.T) {
+	initLogsTest()
+
+	os.MkdirAll(".tmp", 0744)
+	defer os.RemoveAll(".tmp")
+
+	jsonFile := ".tmp/f.audit.json"
+	err := ioutil.WriteFile(jsonFile, []byte(jsonContent), 0644)
+	if err != nil {
+		t.Fatal("Could not create infile needed in test: " + jsonFile)
+	}
+
+	err = parseFlags([]string{"audit2prov", jsonFile})
+	if err != nil {
+		t.Fatal("audit2prov command failed:", err.Error())
+	}
+	provFile := ".tmp/f.audit.prov.json"
+	provBytes, err := ioutil.ReadFile(provFile)
+	if err != nil {
+		t.Fatal("`scipipe audit2prov` command failed to create PROV-JSON file: " + provFile)
+	}
+	doc := &scipipe.PROVDocument{}
+	err = json.Unmarshal(provBytes, doc)
+	if err != nil {
+		t.Fatalf("Could not parse PROV-JSON file %s: %v", provFile, err)
+	}
+	if len(doc.Activity) != 2 {
+		t.Errorf("Expected 2 activities in PROV-JSON, but got %d", len(doc.Activity))
+	}
+	if _, ok := doc.Entity["file:fooer.foo.txt"]; !ok {
+		t.Errorf("Upstream file not found among entities in PROV-JSON: %v", doc.Entity)
+	}
+}
+
 func TestAudit2HTMLCmd(t *testing.T) {
 	initLogsTest()
 
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scipipe/scipipe"
+)
+
+const (
+	roCrateMetadataFile = "ro-crate-metadata.json"
+	roCrateContext      = "https://w3id.org/ro/crate/1.1/context"
+	roCrateSpec         = "https://w3id.org/ro/crate/1.1"
+	// roCrateDefaultMaxSize is the default size, in bytes, above which files
+	// are only described in the metadata, rather than included in the crate
+	roCrateDefaultMaxSize = 100 * 1024 * 1024
+)
+
+// createROCrate packages an output file of a workflow, together with all the
+// upstream files in its audit trail, their audit files, and metadata
+// describing the run, as an RO-Crate (https://w3id.org/ro/crate), in a
+// directory or a zip file. args are the arguments following the rocrate
+// command.
+func createROCrate(args []string) error {
+	flags := flag.NewFlagSet("rocrate", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	crateOut := flags.String("o", "", "The crate directory, or zip file (ending with .zip), to create (default: <outfile>.crate)")
+	maxSize := flags.Int64("max-size", roCrateDefaultMaxSize, "Files larger than this (in bytes) are only described in the metadata, and not included")
+	err := flags.Parse(args)
+	if err != nil {
+		return errWrap(err, "Could not parse flags for the rocrate command")
+	}
+	if flags.NArg() != 1 {
+		return errors.New("Specify one output file to package")
+	}
+	outFile := flags.Arg(0)
+	if *crateOut == "" {
+		*crateOut = outFile + ".crate"
+	}
+
+	auditFile := outFile + ".audit.json"
+	if _, err := os.Stat(auditFile); err != nil {
+		return errWrap(err, "Could not find audit file for: "+outFile)
+	}
+	auditInfo := scipipe.UnmarshalAuditInfoJSONFile(auditFile)
+
+	var cw crateWriter
+	if strings.HasSuffix(*crateOut, ".zip") {
+		cw, err = newZipCrateWriter(*crateOut)
+	} else {
+		cw, err = newDirCrateWriter(*crateOut)
+	}
+	if err != nil {
+		return errWrapf(err, "Could not create crate: %s", *crateOut)
+	}
+
+	err = writeROCrate(cw, outFile, auditInfo, *maxSize)
+	closeErr := cw.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return errWrapf(closeErr, "Could not finish writing crate: %s", *crateOut)
+	}
+	Info.Println("Wrote RO-Crate to:", *crateOut)
+	return nil
+}
+
+// writeROCrate writes the output file outFile, with the audit info
+// auditInfo, and all files in its audit trail, together with the RO-Crate
+// metadata, to cw
+func writeROCrate(cw crateWriter, outFile string, auditInfo *scipipe.AuditInfo, maxSize int64) error {
+	meta := newROCrateMetadata(outFile)
+
+	// Collect the files, and tasks, in the audit trail
+	paths := map[string]bool{outFile: true}
+	tasks := []*scipipe.AuditInfo{}
+	for _, ai := range crateAuditInfos(auditInfo) {
+		for path := range ai.Upstream {
+			paths[path] = true
+		}
+		if ai.ProcessName != "" || ai.Command != "" {
+			tasks = append(tasks, ai)
+		}
+	}
+	// The top-level audit info might not list its own output file, in audit
+	// files from older versions of SciPipe
+	if len(auditInfo.OutFiles) == 0 {
+		auditInfo.OutFiles = map[string]string{"out": outFile}
+	}
+
+	for _, path := range sortedBoolMapKeys(paths) {
+		fileID, err := addCrateFile(cw, meta, path, maxSize)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path + ".audit.json"); err == nil {
+			// Audit files are always included, whatever their size
+			auditID, err := addCrateFile(cw, meta, path+".audit.json", math.MaxInt64)
+			if err != nil {
+				return err
+			}
+			meta.entity(auditID)["about"] = map[string]string{"@id": fileID}
+			meta.entity(auditID)["description"] = "SciPipe audit log for " + path
+		}
+	}
+	root := meta.entity("./")
+	root["mainEntity"] = map[string]string{"@id": crateFileID(outFile)}
+
+	for _, ai := range tasks {
+		action := map[string]interface{}{
+			"@id":         "#task-" + ai.ID,
+			"@type":       "CreateAction",
+			"name":        ai.ProcessName,
+			"description": ai.Command,
+			"instrument":  map[string]string{"@id": "#scipipe"},
+			"object":      crateFileRefs(auditInfoMapKeys(ai.Upstream)),
+			"result":      crateFileRefs(stringMapValues(ai.OutFiles)),
+		}
+		if !ai.StartTime.IsZero() {
+			action["startTime"] = ai.StartTime.Format(time.RFC3339)
+		}
+		if !ai.FinishTime.IsZero() {
+			action["endTime"] = ai.FinishTime.Format(time.RFC3339)
+		}
+		if len(ai.Params) > 0 {
+			action["scipipe:params"] = ai.Params
+		}
+		meta.add(action)
+		root["mentions"] = append(root["mentions"].([]map[string]string), map[string]string{"@id": "#task-" + ai.ID})
+	}
+
+	metaJSON, err := json.MarshalIndent(meta.document(), "", "  ")
+	if err != nil {
+		return errWrap(err, "Could not create RO-Crate metadata")
+	}
+	return cw.WriteBytes(roCrateMetadataFile, append(metaJSON, '\n'))
+}
+
+// addCrateFile adds the file at path to the crate, unless it is larger than
+// maxSize or does not exist, and describes it in the metadata in either case.
+// It returns the ID of the file in the metadata.
+func addCrateFile(cw crateWriter, meta *roCrateMetadata, path string, maxSize int64) (string, error) {
+	fileID := crateFileID(path)
+	entity := map[string]interface{}{
+		"@id":   fileID,
+		"@type": "File",
+		"name":  filepath.Base(path),
+	}
+	fileInfo, err := os.Stat(path)
+	switch {
+	case err != nil:
+		entity["description"] = "Not included in the crate, since the file was not found: " + path
+	case fileInfo.IsDir():
+		entity["@type"] = "Dataset"
+		entity["description"] = "Not included in the crate, since it is a directory: " + path
+	case fileInfo.Size() > maxSize:
+		entity["contentSize"] = fmt.Sprintf("%d", fileInfo.Size())
+		entity["dateModified"] = fileInfo.ModTime().Format(time.RFC3339)
+		entity["description"] = fmt.Sprintf("Not included in the crate, since it is larger than %d bytes: %s", maxSize, path)
+	default:
+		entity["contentSize"] = fmt.Sprintf("%d", fileInfo.Size())
+		entity["dateModified"] = fileInfo.ModTime().Format(time.RFC3339)
+		err := cw.CopyFile(crateFilePath(path), path)
+		if err != nil {
+			return "", errWrapf(err, "Could not add file to crate: %s", path)
+		}
+		root := meta.entity("./")
+		root["hasPart"] = append(root["hasPart"].([]map[string]string), map[string]string{"@id": fileID})
+	}
+	meta.add(entity)
+	return fileID, nil
+}
+
+// crateFilePath returns the path of the file at path inside the crate. Files
+// below the current directory keep their relative paths, while other files
+// are placed in the "external" folder.
+func crateFilePath(path string) string {
+	path = filepath.Clean(path)
+	if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return filepath.Join("external", strings.TrimLeft(strings.Replace(path, "..", "_", -1), string(filepath.Separator)))
+	}
+	return path
+}
+
+// crateFileID returns the ID of the file at path in the RO-Crate metadata,
+// which is its path in the crate, URL-encoded
+func crateFileID(path string) string {
+	parts := strings.Split(filepath.ToSlash(crateFilePath(path)), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// crateAuditInfos returns auditInfo and all its upstream audit infos, sorted
+// by start time and ID
+func crateAuditInfos(auditInfo *scipipe.AuditInfo) []*scipipe.AuditInfo {
+	ais := []*scipipe.AuditInfo{}
+	for _, ai := range extractAuditInfosByID(auditInfo) {
+		ais = append(ais, ai)
+	}
+	sort.Slice(ais, func(i, j int) bool {
+		if ais[i].StartTime.Equal(ais[j].StartTime) {
+			return ais[i].ID < ais[j].ID
+		}
+		return ais[i].StartTime.Before(ais[j].StartTime)
+	})
+	return ais
+}
+
+func crateFileRefs(paths []string) []map[string]string {
+	sort.Strings(paths)
+	refs := []map[string]string{}
+	for _, path := range paths {
+		refs = append(refs, map[string]string{"@id": crateFileID(path)})
+	}
+	return refs
+}
+
+func auditInfoMapKeys(m map[string]*scipipe.AuditInfo) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func stringMapValues(m map[string]string) []string {
+	values := []string{}
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
+
+func sortedBoolMapKeys(m map[string]bool) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ----------------------------------------------------------------------------
+// RO-Crate metadata
+// ----------------------------------------------------------------------------
+
+// roCrateMetadata contains the entities of the @graph of an RO-Crate
+// metadata file, in the order they were added
+type roCrateMetadata struct {
+	entities []map[string]interface{}
+	byID     map[string]map[string]interface{}
+}
+
+func newROCrateMetadata(outFile string) *roCrateMetadata {
+	meta := &roCrateMetadata{byID: map[string]map[string]interface{}{}}
+	meta.add(map[string]interface{}{
+		"@id":        roCrateMetadataFile,
+		"@type":      "CreativeWork",
+		"conformsTo": map[string]string{"@id": roCrateSpec},
+		"about":      map[string]string{"@id": "./"},
+	})
+	meta.add(map[string]interface{}{
+		"@id":           "./",
+		"@type":         "Dataset",
+		"name":          "SciPipe workflow output: " + outFile,
+		"description":   "The file " + outFile + ", produced by a SciPipe workflow, together with the files it was produced from, and their audit logs",
+		"datePublished": time.Now().Format(time.RFC3339),
+		"hasPart":       []map[string]string{},
+		"mentions":      []map[string]string{},
+	})
+	meta.add(map[string]interface{}{
+		"@id":     "#scipipe",
+		"@type":   "SoftwareApplication",
+		"name":    "SciPipe",
+		"url":     "http://scipipe.org",
+		"version": scipipe.Version,
+	})
+	return meta
+}
+
+// add adds entity to the metadata, unless an entity with the same @id is
+// already added
+func (meta *roCrateMetadata) add(entity map[string]interface{}) {
+	id := entity["@id"].(string)
+	if _, ok := meta.byID[id]; ok {
+		return
+	}
+	meta.entities = append(meta.entities, entity)
+	meta.byID[id] = entity
+}
+
+// entity returns the entity with the @id id
+func (meta *roCrateMetadata) entity(id string) map[string]interface{} {
+	return meta.byID[id]
+}
+
+func (meta *roCrateMetadata) document() map[string]interface{} {
+	return map[string]interface{}{
+		"@context": roCrateContext,
+		"@graph":   meta.entities,
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Crate writers
+// ----------------------------------------------------------------------------
+
+// crateWriter writes the files of an RO-Crate, to a directory or a zip file
+type crateWriter interface {
+	// CopyFile copies the file at srcPath into the crate, at the path name
+	CopyFile(name string, srcPath string) error
+	// WriteBytes writes data to a file in the crate, at the path name
+	WriteBytes(name string, data []byte) error
+	Close() error
+}
+
+// dirCrateWriter writes a crate to a directory
+type dirCrateWriter struct {
+	dir string
+}
+
+func newDirCrateWriter(dir string) (*dirCrateWriter, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return nil, errors.New("Crate directory already exists: " + dir)
+	}
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	return &dirCrateWriter{dir: dir}, nil
+}
+
+func (w *dirCrateWriter) CopyFile(name string, srcPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dstPath := filepath.Join(w.dir, name)
+	err = os.MkdirAll(filepath.Dir(dstPath), 0777)
+	if err != nil {
+		return err
+	}
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (w *dirCrateWriter) WriteBytes(name string, data []byte) error {
+	return ioutil.WriteFile(filepath.Join(w.dir, name), data, 0644)
+}
+
+func (w *dirCrateWriter) Close() error {
+	return nil
+}
+
+// zipCrateWriter writes a crate to a zip file
+type zipCrateWriter struct {
+	file *os.File
+	zw   *zip.Writer
+}
+
+func newZipCrateWriter(path string) (*zipCrateWriter, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, errors.New("Crate zip file already exists: " + path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &zipCrateWriter{file: f, zw: zip.NewWriter(f)}, nil
+}
+
+func (w *zipCrateWriter) CopyFile(name string, srcPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := w.zw.Create(filepath.ToSlash(name))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func (w *zipCrateWriter) WriteBytes(name string, data []byte) error {
+	dst, err := w.zw.Create(filepath.ToSlash(name))
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(data)
+	return err
+}
+
+func (w *zipCrateWriter) Close() error {
+	err := w.zw.Close()
+	if err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const rocrateAuditContent = `{
+	"ID": "taskbbbbbbbbbbbbbbbb",
+	"ProcessName": "merge",
+	"Command": "cat .tmp/rocrate/small.txt .tmp/rocrate/big.txt > .tmp/rocrate/out.txt",
+	"Params": {"sep": "none"},
+	"Tags": {},
+	"StartTime": "2018-06-27T17:50:51.445311702+02:00",
+	"FinishTime": "2018-06-27T17:50:51.451388569+02:00",
+	"ExecTimeNS": 6000000,
+	"OutFiles": {"out": ".tmp/rocrate/out.txt"},
+	"Upstream": {
+		".tmp/rocrate/small.txt": {
+			"ID": "taskaaaaaaaaaaaaaaaa",
+			"ProcessName": "smaller",
+			"Command": "echo small > .tmp/rocrate/small.txt",
+			"Params": {},
+			"Tags": {},
+			"StartTime": "2018-06-27T17:50:51.437331897+02:00",
+			"FinishTime": "2018-06-27T17:50:51.44444825+02:00",
+			"ExecTimeNS": 7000000,
+			"OutFiles": {"out": ".tmp/rocrate/small.txt"},
+			"Upstream": {}
+		},
+		".tmp/rocrate/big.txt": {
+			"ID": "",
+			"Upstream": {}
+		}
+	}
+}`
+
+func TestROCrateCmd(t *testing.T) {
+	initLogsTest()
+	defer os.RemoveAll(".tmp")
+
+	for path, content := range map[string]string{
+		".tmp/rocrate/small.txt":            "small\n",
+		".tmp/rocrate/big.txt":              "a file larger than the maximum size\n",
+		".tmp/rocrate/out.txt":              "merged\n",
+		".tmp/rocrate/out.txt.audit.json":   rocrateAuditContent,
+		".tmp/rocrate/small.txt.audit.json": "{}",
+	} {
+		err := os.MkdirAll(filepath.Dir(path), 0777)
+		if err != nil {
+			t.Fatalf("Could not create directory for %s: %v", path, err)
+		}
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("Could not create file needed in test: %s", path)
+		}
+	}
+
+	// Directory crate
+	crateDir := ".tmp/crate"
+	err := parseFlags([]string{"rocrate", "-o", crateDir, "-max-size", "20", ".tmp/rocrate/out.txt"})
+	if err != nil {
+		t.Fatal("rocrate command failed:", err.Error())
+	}
+	for _, path := range []string{".tmp/rocrate/out.txt", ".tmp/rocrate/out.txt.audit.json", ".tmp/rocrate/small.txt", ".tmp/rocrate/small.txt.audit.json", roCrateMetadataFile} {
+		if _, err := os.Stat(filepath.Join(crateDir, path)); err != nil {
+			t.Errorf("File %s not found in crate: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(crateDir, ".tmp/rocrate/big.txt")); err == nil {
+		t.Errorf("File larger than the maximum size should not be included in crate")
+	}
+
+	metaBytes, err := ioutil.ReadFile(filepath.Join(crateDir, roCrateMetadataFile))
+	if err != nil {
+		t.Fatalf("Could not read RO-Crate metadata: %v", err)
+	}
+	meta := struct {
+		Context string                   `json:"@context"`
+		Graph   []map[string]interface{} `json:"@graph"`
+	}{}
+	err = json.Unmarshal(metaBytes, &meta)
+	if err != nil {
+		t.Fatalf("Could not parse RO-Crate metadata: %v", err)
+	}
+	assertEqualValues(t, roCrateContext, meta.Context)
+
+	entities := map[string]map[string]interface{}{}
+	for _, entity := range meta.Graph {
+		entities[entity["@id"].(string)] = entity
+	}
+	for id, typ := range map[string]string{
+		roCrateMetadataFile:               "CreativeWork",
+		"./":                              "Dataset",
+		"#scipipe":                        "SoftwareApplication",
+		".tmp/rocrate/out.txt":            "File",
+		".tmp/rocrate/small.txt":          "File",
+		".tmp/rocrate/big.txt":            "File",
+		".tmp/rocrate/out.txt.audit.json": "File",
+		"#task-taskaaaaaaaaaaaaaaaa":      "CreateAction",
+		"#task-taskbbbbbbbbbbbbbbbb":      "CreateAction",
+	} {
+		entity, ok := entities[id]
+		if !ok {
+			t.Errorf("Entity %s not found in RO-Crate metadata", id)
+			continue
+		}
+		assertEqualValues(t, typ, entity["@type"])
+	}
+	if len(entities) != 10 {
+		t.Errorf("Expected 10 entities in RO-Crate metadata, but got %d", len(entities))
+	}
+
+	root := entities["./"]
+	assertEqualValues(t, map[string]interface{}{"@id": ".tmp/rocrate/out.txt"}, root["mainEntity"])
+	parts := []string{}
+	for _, part := range root["hasPart"].([]interface{}) {
+		parts = append(parts, part.(map[string]interface{})["@id"].(string))
+	}
+	sort.Strings(parts)
+	assertEqualValues(t, []string{".tmp/rocrate/out.txt", ".tmp/rocrate/out.txt.audit.json", ".tmp/rocrate/small.txt", ".tmp/rocrate/small.txt.audit.json"}, parts)
+	assertEqualValues(t, "36", entities[".tmp/rocrate/big.txt"]["contentSize"])
+
+	action := entities["#task-taskbbbbbbbbbbbbbbbb"]
+	assertEqualValues(t, "merge", action["name"])
+	assertEqualValues(t, []interface{}{map[string]interface{}{"@id": ".tmp/rocrate/big.txt"}, map[string]interface{}{"@id": ".tmp/rocrate/small.txt"}}, action["object"])
+	assertEqualValues(t, []interface{}{map[string]interface{}{"@id": ".tmp/rocrate/out.txt"}}, action["result"])
+
+	// Creating the crate again should fail, rather than overwrite it
+	err = parseFlags([]string{"rocrate", "-o", crateDir, ".tmp/rocrate/out.txt"})
+	if err == nil {
+		t.Errorf("Expected error when crate directory already exists")
+	}
+
+	// Zip crate
+	crateZip := ".tmp/crate.zip"
+	err = parseFlags([]string{"rocrate", "-o", crateZip, "-max-size", "20", ".tmp/rocrate/out.txt"})
+	if err != nil {
+		t.Fatal("rocrate command failed:", err.Error())
+	}
+	zr, err := zip.OpenReader(crateZip)
+	if err != nil {
+		t.Fatalf("Could not open crate zip file: %v", err)
+	}
+	defer zr.Close()
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	assertEqualValues(t, []string{".tmp/rocrate/out.txt", ".tmp/rocrate/out.txt.audit.json", ".tmp/rocrate/small.txt", ".tmp/rocrate/small.txt.audit.json", roCrateMetadataFile}, names)
+}
+
+func TestCrateFilePath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"data/a.txt":     "data/a.txt",
+		"./data/a.txt":   "data/a.txt",
+		"../data/a.txt":  "external/_/data/a.txt",
+		"/data/a.txt":    "external/data/a.txt",
+		"data/../a.txt":  "a.txt",
+		"data/a b#c.txt": "data/a b#c.txt",
+	} {
+		assertEqualValues(t, expected, crateFilePath(path))
+	}
+	assertEqualValues(t, "data/a%20b%23c.txt", crateFileID("data/a b#c.txt"))
+}
+
+func assertEqualValues(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Values are not equal (Expected: %v, Actual: %v)\n", expected, actual)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/scipipe/scipipe"
+	"github.com/scipipe/scipipe/components"
+	"gopkg.in/yaml.v3"
+)
+
+// workflowDef is a declarative definition of a workflow, as read from a YAML
+// or JSON file
+type workflowDef struct {
+	Name            string          `yaml:"name"`
+	ConcurrentTasks int             `yaml:"concurrent_tasks"`
+	LogFile         string          `yaml:"log_file"`
+	ShowProgress    bool            `yaml:"show_progress"`
+	LogFormat       string          `yaml:"log_format"`
+	TraceFile       string          `yaml:"trace_file"`
+	PrintSummary    bool            `yaml:"print_summary"`
+	Processes       []processDef    `yaml:"processes"`
+	Components      []componentDef  `yaml:"components"`
+	Connections     []connectionDef `yaml:"connections"`
+}
+
+// processDef defines a scipipe.Process, based on a command pattern
+type processDef struct {
+	Name    string              `yaml:"name"`
+	Command string              `yaml:"cmd"`
+	Out     map[string]string   `yaml:"out"`
+	Cores   int                 `yaml:"cores"`
+	Params  map[string][]string `yaml:"params"`
+}
+
+// componentDef defines one of the processes in the components library. Which
+// fields are used depends on the type of component.
+type componentDef struct {
+	Name          string   `yaml:"name"`
+	Type          string   `yaml:"type"`
+	Paths         []string `yaml:"paths"`
+	Patterns      []string `yaml:"patterns"`
+	Params        []string `yaml:"params"`
+	Path          string   `yaml:"path"`
+	Command       string   `yaml:"cmd"`
+	LinesPerSplit int      `yaml:"lines_per_split"`
+	GroupByTag    string   `yaml:"group_by_tag"`
+}
+
+// connectionDef defines a connection between an out-port and an in-port (or
+// between a param out-port and a param in-port), on the form PROCNAME.PORTNAME
+type connectionDef struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// runWorkflowFile builds a workflow from the definition in the YAML or JSON
+// file filePath, and runs it
+func runWorkflowFile(filePath string) error {
+	wfDef, err := loadWorkflowDef(filePath)
+	if err != nil {
+		return err
+	}
+	wf, err := buildWorkflow(wfDef)
+	if err != nil {
+		return errWrap(err, "Could not build workflow from file: "+filePath)
+	}
+	wf.Run()
+	return nil
+}
+
+// loadWorkflowDef reads a workflow definition from a YAML or JSON file (JSON
+// being a subset of YAML). Unknown keys, such as misspelled ones, are
+// reported as errors, rather than silently ignored.
+func loadWorkflowDef(filePath string) (*workflowDef, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, errWrap(err, "Could not read workflow file: "+filePath)
+	}
+	defer f.Close()
+	wfDef := &workflowDef{}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(wfDef)
+	if err != nil && err != io.EOF {
+		return nil, errWrap(err, "Could not parse workflow file: "+filePath)
+	}
+	return wfDef, nil
+}
+
+// buildWorkflow creates a workflow with processes, components and connections
+// according to the workflow definition wfDef
+func buildWorkflow(wfDef *workflowDef) (*scipipe.Workflow, error) {
+	if wfDef.Name == "" {
+		return nil, errors.New("No workflow name specified")
+	}
+	concurrentTasks := wfDef.ConcurrentTasks
+	if concurrentTasks == 0 {
+		concurrentTasks = 1
+	}
+
+	var wf *scipipe.Workflow
+	if wfDef.LogFile != "" {
+		wf = scipipe.NewWorkflowCustomLogFile(wfDef.Name, concurrentTasks, wfDef.LogFile)
+	} else {
+		wf = scipipe.NewWorkflow(wfDef.Name, concurrentTasks)
+	}
+	wf.ShowProgress = wfDef.ShowProgress
+	wf.TraceFile = wfDef.TraceFile
+	wf.PrintSummary = wfDef.PrintSummary
+	switch wfDef.LogFormat {
+	case "", "text":
+	case "json":
+		scipipe.SetLogFormat(scipipe.LogFormatJSON)
+	default:
+		return nil, fmt.Errorf("Unknown log format (should be text or json): %s", wfDef.LogFormat)
+	}
+
+	for _, procDef := range wfDef.Processes {
+		err := addProcess(wf, procDef)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, compDef := range wfDef.Components {
+		err := addComponent(wf, compDef)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, connDef := range wfDef.Connections {
+		err := connect(wf, connDef)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return wf, nil
+}
+
+func addProcess(wf *scipipe.Workflow, procDef processDef) error {
+	if procDef.Name == "" {
+		return errors.New("Process without name found")
+	}
+	if procDef.Command == "" {
+		return fmt.Errorf("No command specified for process: %s", procDef.Name)
+	}
+	if _, ok := wf.Procs()[procDef.Name]; ok {
+		return fmt.Errorf("More than one process with name: %s", procDef.Name)
+	}
+
+	proc := wf.NewProc(procDef.Name, procDef.Command)
+	for _, outName := range sortedKeys(procDef.Out) {
+		proc.SetOut(outName, procDef.Out[outName])
+	}
+	if procDef.Cores > 0 {
+		proc.CoresPerTask = procDef.Cores
+	}
+	for paramName, values := range procDef.Params {
+		proc.InParam(paramName).FromStr(values...)
+	}
+	return nil
+}
+
+func addComponent(wf *scipipe.Workflow, compDef componentDef) error {
+	if compDef.Name == "" {
+		return errors.New("Component without name found")
+	}
+	if _, ok := wf.Procs()[compDef.Name]; ok {
+		return fmt.Errorf("More than one process with name: %s", compDef.Name)
+	}
+
+	switch compDef.Type {
+	case "CommandToParams":
+		components.NewCommandToParams(wf, compDef.Name, compDef.Command)
+	case "Concatenator":
+		cct := components.NewConcatenator(wf, compDef.Name, compDef.Path)
+		cct.GroupByTag = compDef.GroupByTag
+	case "FileCombinator":
+		components.NewFileCombinator(wf, compDef.Name)
+	case "FileGlobber":
+		components.NewFileGlobber(wf, compDef.Name, compDef.Patterns...)
+	case "FileSource":
+		components.NewFileSource(wf, compDef.Name, compDef.Paths...)
+	case "FileSplitter":
+		components.NewFileSplitter(wf, compDef.Name, compDef.LinesPerSplit)
+	case "GroupByTag":
+		if compDef.GroupByTag == "" {
+			return fmt.Errorf("No group_by_tag specified for component: %s", compDef.Name)
+		}
+		components.NewGroupByTag(wf, compDef.Name, compDef.GroupByTag)
+	case "FileToParamsReader":
+		components.NewFileToParamsReader(wf, compDef.Name, compDef.Path)
+	case "ParamCombinator":
+		components.NewParamCombinator(wf, compDef.Name)
+	case "ParamSource":
+		components.NewParamSource(wf, compDef.Name, compDef.Params...)
+	case "StreamToSubStream":
+		components.NewStreamToSubStream(wf, compDef.Name)
+	default:
+		return fmt.Errorf("Unknown type (%s) for component: %s", compDef.Type, compDef.Name)
+	}
+	return nil
+}
+
+// connect connects the ports in the connection definition connDef, which
+// can be either normal (file) ports, or param ports
+func connect(wf *scipipe.Workflow, connDef connectionDef) error {
+	fromProc, fromPort, err := splitPortAddress(wf, connDef.From)
+	if err != nil {
+		return err
+	}
+	toProc, toPort, err := splitPortAddress(wf, connDef.To)
+	if err != nil {
+		return err
+	}
+	initDynamicPorts(fromProc, fromPort)
+	initDynamicPorts(toProc, toPort)
+
+	if opt, ok := fromProc.OutPorts()[fromPort]; ok {
+		ipt, ok := toProc.InPorts()[toPort]
+		if !ok {
+			return fmt.Errorf("No in-port named (%s) in process (%s), to connect out-port (%s) to", toPort, toProc.Name(), connDef.From)
+		}
+		opt.To(ipt)
+		return nil
+	}
+	if pop, ok := fromProc.OutParamPorts()[fromPort]; ok {
+		pip, ok := toProc.InParamPorts()[toPort]
+		if !ok {
+			if p, isProc := toProc.(*scipipe.Process); isProc {
+				pip = p.InParam(toPort)
+			} else {
+				return fmt.Errorf("No param in-port named (%s) in process (%s), to connect param out-port (%s) to", toPort, toProc.Name(), connDef.From)
+			}
+		}
+		pop.To(pip)
+		return nil
+	}
+	return fmt.Errorf("No out-port or param out-port named (%s) in process (%s)", fromPort, fromProc.Name())
+}
+
+// splitPortAddress splits a port address on the form PROCNAME.PORTNAME into
+// the process and the port name
+func splitPortAddress(wf *scipipe.Workflow, address string) (scipipe.WorkflowProcess, string, error) {
+	dotIdx := strings.LastIndex(address, ".")
+	if dotIdx < 1 || dotIdx == len(address)-1 {
+		return nil, "", fmt.Errorf("Port address (%s) is not on the form PROCNAME.PORTNAME", address)
+	}
+	procName, portName := address[:dotIdx], address[dotIdx+1:]
+	proc, ok := wf.Procs()[procName]
+	if !ok {
+		return nil, "", fmt.Errorf("No process named (%s), in port address (%s)", procName, address)
+	}
+	return proc, portName, nil
+}
+
+// initDynamicPorts creates ports for components that create their ports on
+// demand, when first accessed
+func initDynamicPorts(proc scipipe.WorkflowProcess, portName string) {
+	switch p := proc.(type) {
+	case *components.FileCombinator:
+		p.In(portName)
+	case *components.ParamCombinator:
+		p.InParam(portName)
+	}
+}
+
+func sortedKeys(kv map[string]string) []string {
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
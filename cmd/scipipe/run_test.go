@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunCmdYAML(t *testing.T) {
+	initLogsTest()
+	os.MkdirAll(".tmp", 0744)
+
+	wfPath := ".tmp/testwf.yaml"
+	err := ioutil.WriteFile(wfPath, []byte(`name: testwf
+concurrent_tasks: 2
+log_file: .tmp/testwf.log
+processes:
+  - name: foo
+    cmd: echo {p:word} > {o:out}
+    out:
+      out: .tmp/{p:word}.txt
+    params:
+      word: [foo, fooo]
+  - name: f2b
+    cmd: sed 's/foo/bar/g' {i:in} > {o:out}
+    out:
+      out: "{i:in|%.txt}.bar.txt"
+connections:
+  - from: foo.out
+    to: f2b.in
+`), 0644)
+	if err != nil {
+		t.Fatal("Could not write workflow file needed in test:", wfPath)
+	}
+
+	err = parseFlags([]string{"run", wfPath})
+	if err != nil {
+		t.Fatal("Could not run workflow file:", err.Error())
+	}
+
+	for word, expected := range map[string]string{"foo": "bar\n", "fooo": "baro\n"} {
+		outFile := ".tmp/" + word + ".bar.txt"
+		dat, err := ioutil.ReadFile(outFile)
+		if err != nil {
+			t.Fatal("Workflow did not produce expected output file:", outFile)
+		}
+		if string(dat) != expected {
+			t.Errorf("Wrong content in file %s. Expected: %s, Got: %s", outFile, expected, string(dat))
+		}
+	}
+
+	os.RemoveAll(".tmp")
+}
+
+func TestRunCmdJSONWithComponents(t *testing.T) {
+	initLogsTest()
+	os.MkdirAll(".tmp", 0744)
+
+	wfPath := ".tmp/testwf.json"
+	err := ioutil.WriteFile(wfPath, []byte(`{
+  "name": "testwf",
+  "log_file": ".tmp/testwf.log",
+  "components": [
+    {"name": "params", "type": "ParamSource", "params": ["a", "b"]}
+  ],
+  "processes": [
+    {"name": "echo", "cmd": "echo {p:letter} > {o:out}", "out": {"out": ".tmp/letter_{p:letter}.txt"}}
+  ],
+  "connections": [
+    {"from": "params.out", "to": "echo.letter"}
+  ]
+}`), 0644)
+	if err != nil {
+		t.Fatal("Could not write workflow file needed in test:", wfPath)
+	}
+
+	err = parseFlags([]string{"run", wfPath})
+	if err != nil {
+		t.Fatal("Could not run workflow file:", err.Error())
+	}
+
+	for _, letter := range []string{"a", "b"} {
+		outFile := ".tmp/letter_" + letter + ".txt"
+		if _, err := os.Stat(outFile); os.IsNotExist(err) {
+			t.Error("Workflow did not produce expected output file:", outFile)
+		}
+	}
+
+	os.RemoveAll(".tmp")
+}
+
+func TestLoadWorkflowDefUnknownKeys(t *testing.T) {
+	initLogsTest()
+	os.MkdirAll(".tmp", 0744)
+	defer os.RemoveAll(".tmp")
+
+	for fileName, content := range map[string]string{
+		"typo.yaml": "name: testwf\nprocesses:\n  - name: foo\n    command: echo foo > {o:out}\n",
+		"typo.json": `{"name": "testwf", "processes": [{"name": "foo", "command": "echo foo > {o:out}"}]}`,
+	} {
+		wfPath := ".tmp/" + fileName
+		err := ioutil.WriteFile(wfPath, []byte(content), 0644)
+		if err != nil {
+			t.Fatal("Could not write workflow file needed in test:", wfPath)
+		}
+		_, err = loadWorkflowDef(wfPath)
+		if err == nil {
+			t.Errorf("Expected error for misspelled key in %s, but got no error", wfPath)
+			continue
+		}
+		if !strings.Contains(err.Error(), "command") {
+			t.Errorf("Expected error mentioning the misspelled key 'command' for %s, but got: %s", wfPath, err.Error())
+		}
+	}
+}
+
+func TestBuildWorkflowErrors(t *testing.T) {
+	initLogsTest()
+
+	for expectedMsg, wfDef := range map[string]*workflowDef{
+		"No workflow name specified": {},
+		"No command specified for process: foo": {
+			Name:      "testwf",
+			Processes: []processDef{{Name: "foo"}},
+		},
+		"Unknown type (Bogus) for component: bogus": {
+			Name:       "testwf",
+			Components: []componentDef{{Name: "bogus", Type: "Bogus"}},
+		},
+		"No process named (bar)": {
+			Name:        "testwf",
+			Processes:   []processDef{{Name: "foo", Command: "echo foo > {o:out}"}},
+			Connections: []connectionDef{{From: "foo.out", To: "bar.in"}},
+		},
+		"No in-port named (inn) in process (cat)": {
+			Name: "testwf",
+			Processes: []processDef{
+				{Name: "foo", Command: "echo foo > {o:out}"},
+				{Name: "cat", Command: "cat {i:in} > {o:out}"},
+			},
+			Connections: []connectionDef{{From: "foo.out", To: "cat.inn"}},
+		},
+		"Unknown log format (should be text or json): xml": {
+			Name:      "testwf",
+			LogFormat: "xml",
+		},
+		"is not on the form PROCNAME.PORTNAME": {
+			Name:        "testwf",
+			Processes:   []processDef{{Name: "foo", Command: "echo foo > {o:out}"}},
+			Connections: []connectionDef{{From: "foo", To: "bar.in"}},
+		},
+	} {
+		_, err := buildWorkflow(wfDef)
+		if err == nil {
+			t.Errorf("Expected error containing '%s', but got no error", expectedMsg)
+			continue
+		}
+		if !strings.Contains(err.Error(), expectedMsg) {
+			t.Errorf("Expected error containing '%s', but got: %s", expectedMsg, err.Error())
+		}
+	}
+}
@@ -43,9 +43,20 @@ func Check(err error) {
 // Fail logs the error message, so that it will be possible to improve error
 // messages in one place
 func Fail(vs ...interface{}) {
-	Error.Println(vs...)
+	failEvent(nil, vs...)
+}
+
+// failEvent is like Fail, but if entry is not nil, the message is logged with
+// the structured fields in entry, when the log format is JSON (See logEvent)
+func failEvent(entry *LogEntry, vs ...interface{}) {
+	if entry != nil {
+		logEvent(Error, *entry, fmt.Sprintln(vs...))
+	} else {
+		Error.Println(vs...)
+	}
 	//Error.Println("Printing stack trace (read from bottom to find the workflow code that hit this error):")
 	//debug.PrintStack()
+	recordFailedRuns()
 	os.Exit(1) // Indicates a "general error" (See http://www.tldp.org/LDP/abs/html/exitcodes.html)
 }
 
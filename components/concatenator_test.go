@@ -112,6 +112,36 @@ B3
 	cleanUpTestFiles()
 }
 
+func TestMultipleConcatenators(t *testing.T) {
+	createTestFiles()
+
+	// Two concatenators, none of which have out-ports, should both drive the
+	// workflow
+	wf := scipipe.NewWorkflow("wf", 4)
+	txtSrc := NewFileSource(wf, "txtsrc", tempDir+"/a.txt", tempDir+"/b.txt")
+	csvSrc := NewFileSource(wf, "csvsrc", tempDir+"/a.csv", tempDir+"/b.csv")
+	txtConcat := NewConcatenator(wf, "txt_concat", tempDir+"/concat_txt.txt")
+	txtConcat.In().From(txtSrc.Out())
+	csvConcat := NewConcatenator(wf, "csv_concat", tempDir+"/concat_csv.txt")
+	csvConcat.In().From(csvSrc.Out())
+	wf.Run()
+
+	for fn, wantContent := range map[string]string{
+		tempDir + "/concat_txt.txt": "A1\nB1\n",
+		tempDir + "/concat_csv.txt": "A2\nB2\n",
+	} {
+		haveContentByte, err := ioutil.ReadFile(fn)
+		if err != nil {
+			t.Fatalf("Could not read file: %s", fn)
+		}
+		if string(haveContentByte) != wantContent {
+			t.Fatalf("Wanted: %s but got %s", wantContent, string(haveContentByte))
+		}
+	}
+
+	cleanUpTestFiles()
+}
+
 func createTestFiles() {
 	os.MkdirAll(tempDir, 0744)
 	for name, content := range testFiles {
@@ -0,0 +1,87 @@
+package components
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+
+	"github.com/scipipe/scipipe"
+)
+
+// GroupByTag collects all IPs received on its in-port having the same value
+// for the tag tagName, and sends each such group as one IP, with the IPs of
+// the group on its substream, on the out-port. This can be used together with
+// an in-port with the join modifier, such as {i:in|join: }, to for example
+// merge all files from the same sample.
+//
+// The substream IPs have the tag tagName set to the value of the group, so
+// that it can be used in path patterns downstream (such as {t:in.sample}).
+// Since IPs with the same tag value might arrive at any point in the stream,
+// the groups are sent only when the in-port is closed, in the order in which
+// the tag values were first seen.
+type GroupByTag struct {
+	scipipe.BaseProcess
+	tagName string
+}
+
+// NewGroupByTag returns an initialized GroupByTag process, that groups IPs by
+// the value of the tag tagName
+func NewGroupByTag(wf *scipipe.Workflow, name string, tagName string) *GroupByTag {
+	p := &GroupByTag{
+		BaseProcess: scipipe.NewBaseProcess(wf, name),
+		tagName:     tagName,
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "substreams")
+	wf.AddProc(p)
+	return p
+}
+
+// In returns the in-port
+func (p *GroupByTag) In() *scipipe.InPort { return p.InPort("in") }
+
+// OutSubStreams returns the out-port, sending one substream IP per group
+func (p *GroupByTag) OutSubStreams() *scipipe.OutPort { return p.OutPort("substreams") }
+
+// Run runs the GroupByTag process
+func (p *GroupByTag) Run() {
+	defer p.CloseAllOutPorts()
+
+	groups := map[string][]*scipipe.FileIP{}
+	tagValues := []string{}
+	for ip := range p.In().Chan {
+		tagValue := ip.Tag(p.tagName)
+		if tagValue == "" {
+			scipipe.Warning.Printf("[Process:%s] IP without value for tag %s, grouping it with other such IPs: %s\n", p.Name(), p.tagName, ip.Path())
+		}
+		if _, ok := groups[tagValue]; !ok {
+			tagValues = append(tagValues, tagValue)
+		}
+		groups[tagValue] = append(groups[tagValue], ip)
+	}
+
+	unsafeChars := regexp.MustCompile("[^A-Za-z0-9_.-]")
+	for _, tagValue := range tagValues {
+		// create a temporary file, with a _scipipe prefix, to represent the group
+		tmpfile, err := ioutil.TempFile("", "_scipipe_tmp."+unsafeChars.ReplaceAllString(p.Name()+"."+tagValue, "_")+".")
+		if err != nil {
+			p.Fail(err)
+		}
+		tmpfile.Close()
+		defer os.Remove(tmpfile.Name())
+
+		subStreamIP, err := scipipe.NewFileIP(tmpfile.Name())
+		if err != nil {
+			p.Fail(err)
+		}
+		subStreamIP.AddTag(p.tagName, tagValue)
+		subStreamIP.SubStream.Chan = make(chan *scipipe.FileIP, len(groups[tagValue]))
+		for _, ip := range groups[tagValue] {
+			subStreamIP.SubStream.Chan <- ip
+		}
+		close(subStreamIP.SubStream.Chan)
+
+		scipipe.Debug.Printf("[Process:%s] Sending sub-stream IP with %d IPs for %s=%s\n", p.Name(), len(groups[tagValue]), p.tagName, tagValue)
+		p.OutSubStreams().Send(subStreamIP)
+	}
+}
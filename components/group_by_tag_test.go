@@ -0,0 +1,63 @@
+package components
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scipipe/scipipe"
+)
+
+func TestGroupByTag(t *testing.T) {
+	os.MkdirAll(tempDir, 0744)
+	laneFiles := map[string]string{
+		"s1_L1.txt": "s1L1\n",
+		"s2_L1.txt": "s2L1\n",
+		"s1_L2.txt": "s1L2\n",
+		"s2_L2.txt": "s2L2\n",
+		"s3_L1.txt": "s3L1\n",
+	}
+	for name, content := range laneFiles {
+		ioutil.WriteFile(tempDir+"/"+name, []byte(content), 0644)
+	}
+	defer os.RemoveAll(tempDir)
+
+	wf := scipipe.NewWorkflow("wf", 4)
+	fileSrc := NewFileSource(wf, "filesrc",
+		tempDir+"/s1_L1.txt",
+		tempDir+"/s2_L1.txt",
+		tempDir+"/s1_L2.txt",
+		tempDir+"/s2_L2.txt",
+		tempDir+"/s3_L1.txt")
+
+	sampleTagger := NewMapToTags(wf, "sample_tagger", func(ip *scipipe.FileIP) map[string]string {
+		return map[string]string{"sample": strings.Split(filepath.Base(ip.Path()), "_")[0]}
+	})
+	sampleTagger.In().From(fileSrc.Out())
+
+	grouper := NewGroupByTag(wf, "grouper", "sample")
+	grouper.In().From(sampleTagger.Out())
+
+	merge := wf.NewProc("merge", "cat {i:in|join: } > {o:out}")
+	merge.SetOut("out", tempDir+"/{t:in.sample}.merged.txt")
+	merge.In("in").From(grouper.OutSubStreams())
+
+	wf.Run()
+
+	for sample, wantContent := range map[string]string{
+		"s1": "s1L1\ns1L2\n",
+		"s2": "s2L1\ns2L2\n",
+		"s3": "s3L1\n",
+	} {
+		path := tempDir + "/" + sample + ".merged.txt"
+		haveContent, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Could not read file: %s", path)
+		}
+		if string(haveContent) != wantContent {
+			t.Errorf("Wanted content %q in %s, but got %q", wantContent, path, string(haveContent))
+		}
+	}
+}
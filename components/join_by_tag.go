@@ -0,0 +1,137 @@
+package components
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/scipipe/scipipe"
+)
+
+// JoinByTag is a process that matches IPs arriving on several in-ports, by the
+// value of a tag, such as a sample name. IPs are buffered per in-port until
+// all in-ports have received an IP with the same value for the tag, upon which
+// the matched set of IPs is sent on the out-ports with the same names as the
+// in-ports. Since the sets are sent all at once, downstream processes will
+// receive matching IPs on their in-ports, even if the upstream processes
+// produce them in different orders.
+//
+// IPs that are still unmatched when all in-ports are closed (orphans) are
+// reported with a warning in the log, and can be retrieved with the Orphans
+// method after the workflow has finished.
+type JoinByTag struct {
+	scipipe.BaseProcess
+	tagName    string
+	orphans    map[string][]*scipipe.FileIP
+	orphansMtx sync.Mutex
+}
+
+// NewJoinByTag returns an initialized JoinByTag process, that matches IPs by
+// the value of the tag tagName
+func NewJoinByTag(wf *scipipe.Workflow, name string, tagName string) *JoinByTag {
+	p := &JoinByTag{
+		BaseProcess: scipipe.NewBaseProcess(wf, name),
+		tagName:     tagName,
+		orphans:     map[string][]*scipipe.FileIP{},
+	}
+	wf.AddProc(p)
+	return p
+}
+
+// In returns the in-port named portName, and creates it, together with an
+// out-port with the same name, if it does not exist
+func (p *JoinByTag) In(portName string) *scipipe.InPort {
+	if _, ok := p.InPorts()[portName]; !ok {
+		p.InitInPort(p, portName)
+		p.InitOutPort(p, portName)
+	}
+	return p.InPort(portName)
+}
+
+// Out returns the out-port named portName, sending the IPs received on the
+// in-port with the same name, when they have been matched with IPs on all the
+// other in-ports
+func (p *JoinByTag) Out(portName string) *scipipe.OutPort {
+	if _, ok := p.OutPorts()[portName]; !ok {
+		p.Failf("No out-port named '%s'. Out-ports are created together with in-ports, using In()", portName)
+	}
+	return p.OutPort(portName)
+}
+
+// Orphans returns the IPs, by in-port name, that could not be matched with
+// IPs on all other in-ports. It is populated when the process has finished.
+func (p *JoinByTag) Orphans() map[string][]*scipipe.FileIP {
+	p.orphansMtx.Lock()
+	defer p.orphansMtx.Unlock()
+	return p.orphans
+}
+
+type portIP struct {
+	portName string
+	ip       *scipipe.FileIP
+}
+
+// Run runs the JoinByTag process
+func (p *JoinByTag) Run() {
+	defer p.CloseAllOutPorts()
+
+	// Merge the IPs from all in-ports into one channel
+	merged := make(chan portIP, len(p.InPorts()))
+	wg := &sync.WaitGroup{}
+	for portName, inPort := range p.InPorts() {
+		wg.Add(1)
+		go func(portName string, inPort *scipipe.InPort) {
+			defer wg.Done()
+			for ip := range inPort.Chan {
+				merged <- portIP{portName, ip}
+			}
+		}(portName, inPort)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	// buffers holds the received but not yet matched IPs, by tag value and
+	// in-port name, in the order they arrived
+	buffers := map[string]map[string][]*scipipe.FileIP{}
+	tagValues := []string{}
+	for pip := range merged {
+		tagValue := pip.ip.Tag(p.tagName)
+		if _, ok := buffers[tagValue]; !ok {
+			buffers[tagValue] = map[string][]*scipipe.FileIP{}
+			tagValues = append(tagValues, tagValue)
+		}
+		buffers[tagValue][pip.portName] = append(buffers[tagValue][pip.portName], pip.ip)
+
+		if len(buffers[tagValue]) == len(p.InPorts()) {
+			for portName, ips := range buffers[tagValue] {
+				p.Out(portName).Send(ips[0])
+				if len(ips) == 1 {
+					delete(buffers[tagValue], portName)
+				} else {
+					buffers[tagValue][portName] = ips[1:]
+				}
+			}
+		}
+	}
+
+	p.orphansMtx.Lock()
+	defer p.orphansMtx.Unlock()
+	for _, tagValue := range tagValues {
+		for portName, ips := range buffers[tagValue] {
+			p.orphans[portName] = append(p.orphans[portName], ips...)
+			missingPorts := []string{}
+			for otherPortName := range p.InPorts() {
+				if _, ok := buffers[tagValue][otherPortName]; !ok {
+					missingPorts = append(missingPorts, otherPortName)
+				}
+			}
+			sort.Strings(missingPorts)
+			for _, ip := range ips {
+				scipipe.Warning.Printf("[Process:%s] Orphan IP on in-port %s, with %s=%s, not matched on in-port(s) %s: %s\n",
+					p.Name(), portName, p.tagName, tagValue, strings.Join(missingPorts, ","), ip.Path())
+			}
+		}
+	}
+}
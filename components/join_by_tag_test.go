@@ -0,0 +1,70 @@
+package components
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scipipe/scipipe"
+)
+
+func TestJoinByTag(t *testing.T) {
+	createTestFiles()
+
+	sampleTagger := func(ip *scipipe.FileIP) map[string]string {
+		fileName := filepath.Base(ip.Path())
+		return map[string]string{"sample": strings.Split(fileName, ".")[0]}
+	}
+
+	wf := scipipe.NewWorkflow("wf", 4)
+	txtSrc := NewFileSource(wf, "txtsrc", tempDir+"/a.txt", tempDir+"/b.txt", tempDir+"/c.txt")
+	txtTagger := NewMapToTags(wf, "txt_tagger", sampleTagger)
+	txtTagger.In().From(txtSrc.Out())
+
+	// Deliver the csv files in a different order than the txt files
+	csvSrc := NewFileSource(wf, "csvsrc", tempDir+"/b.csv", tempDir+"/a.csv")
+	csvTagger := NewMapToTags(wf, "csv_tagger", sampleTagger)
+	csvTagger.In().From(csvSrc.Out())
+
+	joiner := NewJoinByTag(wf, "joiner", "sample")
+	joiner.In("txt").From(txtTagger.Out())
+	joiner.In("csv").From(csvTagger.Out())
+
+	paste := wf.NewProc("paste", "cat {i:txt} {i:csv} > {o:out}")
+	paste.SetOut("out", "{i:txt}.joined")
+	paste.In("txt").From(joiner.Out("txt"))
+	paste.In("csv").From(joiner.Out("csv"))
+
+	wf.Run()
+
+	for fileName, wantContent := range map[string]string{
+		"a.txt.joined": "A1A2",
+		"b.txt.joined": "B1B2",
+	} {
+		path := tempDir + "/" + fileName
+		haveContent, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Could not read file: %s", path)
+		}
+		if string(haveContent) != wantContent {
+			t.Errorf("Wanted content %s in %s, but got %s", wantContent, path, string(haveContent))
+		}
+		os.Remove(path)
+		os.Remove(path + ".audit.json")
+	}
+	if _, err := os.Stat(tempDir + "/c.txt.joined"); !os.IsNotExist(err) {
+		t.Error("Orphan file c.txt should not have been joined")
+	}
+
+	orphans := joiner.Orphans()
+	if len(orphans["txt"]) != 1 || orphans["txt"][0].Path() != tempDir+"/c.txt" {
+		t.Errorf("Wanted c.txt as the only orphan on in-port txt, but got: %v", orphans["txt"])
+	}
+	if len(orphans["csv"]) != 0 {
+		t.Errorf("Wanted no orphans on in-port csv, but got: %v", orphans["csv"])
+	}
+
+	cleanUpTestFiles()
+}
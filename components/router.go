@@ -0,0 +1,125 @@
+package components
+
+import (
+	"regexp"
+
+	"github.com/scipipe/scipipe"
+)
+
+// Router is a process that sends each IP received on its in-port to the
+// out-port of the first route whose predicate function returns true for the
+// IP, or to the default out-port if no predicate matches. Routes are added
+// with the Route method, and are tried in the order in which they were added.
+//
+// This can be used to send, for example, small and large samples to different
+// processes:
+//
+//	router := components.NewRouter(wf, "size_router")
+//	router.In().From(samples.Out("out"))
+//	alignSmall.In("in").From(router.Route("small", components.SizeBelow(1000000)))
+//	alignLarge.In("in").From(router.Default())
+type Router struct {
+	scipipe.BaseProcess
+	routes []route
+}
+
+type route struct {
+	portName  string
+	predicate func(ip *scipipe.FileIP) bool
+}
+
+// NewRouter returns an initialized Router process
+func NewRouter(wf *scipipe.Workflow, name string) *Router {
+	p := &Router{
+		BaseProcess: scipipe.NewBaseProcess(wf, name),
+		routes:      []route{},
+	}
+	p.InitInPort(p, "in")
+	p.InitOutPort(p, "default")
+	wf.AddProc(p)
+	return p
+}
+
+// In takes the IPs to route
+func (p *Router) In() *scipipe.InPort { return p.InPort("in") }
+
+// Default outputs the IPs not matched by any of the routes
+func (p *Router) Default() *scipipe.OutPort { return p.OutPort("default") }
+
+// Out returns the out-port of the route named portName
+func (p *Router) Out(portName string) *scipipe.OutPort { return p.OutPort(portName) }
+
+// Route adds a route, sending IPs for which predicate returns true, and which
+// were not matched by any previously added route, to a new out-port named
+// portName, which is returned
+func (p *Router) Route(portName string, predicate func(ip *scipipe.FileIP) bool) *scipipe.OutPort {
+	if _, ok := p.OutPorts()[portName]; ok {
+		p.Failf("Route can not be added, since an out-port named '%s' already exists", portName)
+	}
+	p.InitOutPort(p, portName)
+	p.routes = append(p.routes, route{portName: portName, predicate: predicate})
+	return p.OutPort(portName)
+}
+
+// Run runs the Router process
+func (p *Router) Run() {
+	defer p.CloseAllOutPorts()
+	for ip := range p.In().Chan {
+		p.outPortFor(ip).Send(ip)
+	}
+}
+
+func (p *Router) outPortFor(ip *scipipe.FileIP) *scipipe.OutPort {
+	for _, r := range p.routes {
+		if r.predicate(ip) {
+			return p.OutPort(r.portName)
+		}
+	}
+	return p.Default()
+}
+
+// ----------------------------------------------------------------------------
+// Predicates for use with Router
+// ----------------------------------------------------------------------------
+
+// TagEquals returns a predicate matching IPs having the tag tagName set to
+// value
+func TagEquals(tagName string, value string) func(ip *scipipe.FileIP) bool {
+	return func(ip *scipipe.FileIP) bool {
+		return ip.Tag(tagName) == value
+	}
+}
+
+// ParamEquals returns a predicate matching IPs produced by a task where the
+// parameter paramName was set to value, according to the IP's audit info
+func ParamEquals(paramName string, value string) func(ip *scipipe.FileIP) bool {
+	return func(ip *scipipe.FileIP) bool {
+		paramValue, ok := ip.AuditInfo().Params[paramName]
+		return ok && paramValue == value
+	}
+}
+
+// PathMatches returns a predicate matching IPs whose path matches the regular
+// expression pattern
+func PathMatches(pattern string) func(ip *scipipe.FileIP) bool {
+	regex := regexp.MustCompile(pattern)
+	return func(ip *scipipe.FileIP) bool {
+		return regex.MatchString(ip.Path())
+	}
+}
+
+// SizeBelow returns a predicate matching IPs whose file size is less than
+// sizeBytes bytes
+func SizeBelow(sizeBytes int64) func(ip *scipipe.FileIP) bool {
+	return func(ip *scipipe.FileIP) bool {
+		return ip.Size() < sizeBytes
+	}
+}
+
+// SizeAtLeast returns a predicate matching IPs whose file size is at least
+// sizeBytes bytes
+func SizeAtLeast(sizeBytes int64) func(ip *scipipe.FileIP) bool {
+	return func(ip *scipipe.FileIP) bool {
+		return ip.Size() >= sizeBytes
+	}
+}
@@ -0,0 +1,82 @@
+package components
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/scipipe/scipipe"
+)
+
+func TestRouter(t *testing.T) {
+	createTestFiles()
+	ioutil.WriteFile(tempDir+"/large.txt", []byte("0123456789"), 0644)
+	defer os.Remove(tempDir + "/large.txt")
+
+	wf := scipipe.NewWorkflow("wf", 4)
+	fileSrc := NewFileSource(wf, "filesrc",
+		tempDir+"/a.txt",
+		tempDir+"/a.csv",
+		tempDir+"/b.csv",
+		tempDir+"/c.txt",
+		tempDir+"/large.txt")
+
+	router := NewRouter(wf, "router")
+	router.In().From(fileSrc.Out())
+	router.Route("large", SizeAtLeast(10))
+	router.Route("csv", PathMatches(`\.csv$`))
+	router.Route("b", PathMatches(`/b\.`)) // Never matched, since b.csv is caught by the csv route
+
+	gotPaths := map[string][]string{}
+	gotPathsLock := sync.Mutex{}
+	for _, portName := range []string{"large", "csv", "b", "default"} {
+		portName := portName
+		collector := wf.NewProc("collect_"+portName, "# {i:in}")
+		collector.CustomExecute = func(tsk *scipipe.Task) {
+			gotPathsLock.Lock()
+			gotPaths[portName] = append(gotPaths[portName], tsk.InPath("in"))
+			gotPathsLock.Unlock()
+		}
+		collector.In("in").From(router.Out(portName))
+	}
+	wf.Run()
+
+	for portName, wantPaths := range map[string][]string{
+		"large":   {tempDir + "/large.txt"},
+		"csv":     {tempDir + "/a.csv", tempDir + "/b.csv"},
+		"b":       nil,
+		"default": {tempDir + "/a.txt", tempDir + "/c.txt"},
+	} {
+		sort.Strings(gotPaths[portName])
+		if len(gotPaths[portName]) != len(wantPaths) {
+			t.Fatalf("Wanted paths %v on port %s, but got %v", wantPaths, portName, gotPaths[portName])
+		}
+		for i, wantPath := range wantPaths {
+			if gotPaths[portName][i] != wantPath {
+				t.Errorf("Wanted path %s on port %s, but got %s", wantPath, portName, gotPaths[portName][i])
+			}
+		}
+	}
+
+	cleanUpTestFiles()
+}
+
+func TestRouterPredicates(t *testing.T) {
+	ip, err := scipipe.NewFileIP(tempDir + "/x.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip.AddTag("size", "small")
+
+	if !TagEquals("size", "small")(ip) {
+		t.Error("TagEquals did not match IP with tag size=small")
+	}
+	if TagEquals("size", "large")(ip) {
+		t.Error("TagEquals matched IP without tag size=large")
+	}
+	if !PathMatches(`x\.txt$`)(ip) {
+		t.Error("PathMatches did not match path of IP")
+	}
+}
@@ -0,0 +1,526 @@
+package scipipe
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ----------------------------------------------------------------------------
+// CWL export
+// ----------------------------------------------------------------------------
+
+const cwlVersion = "v1.2"
+
+// ExportCWL writes the workflow as Common Workflow Language (CWL) documents to
+// the folder dir: one CommandLineTool document per process, in a sub-folder
+// named tools, and a Workflow document, named after the workflow, that wires
+// the tools together according to the connections between the ports of the
+// processes.
+//
+// Only processes created from a command pattern (with NewProc) can be
+// exported, and only if they do not use a CustomExecute function, custom path
+// functions (set with SetOutFunc) or tags. Other processes, such as custom Go
+// processes and the ones in the components library, are flagged as not
+// exportable with a warning, and are listed in the doc field of the CWL
+// workflow. Ports that receive their inputs from such processes, or from
+// parameters fed with FromStr, become inputs to the CWL workflow instead.
+// Out-ports not connected to any exported process become outputs of the CWL
+// workflow.
+//
+// Note that each port is exported as taking a single file or parameter value,
+// so that streams of values need to be handled with scattering on the CWL
+// side.
+func (wf *Workflow) ExportCWL(dir string) error {
+	exp := newCWLExporter(wf)
+	err := exp.collectProcs(wf.ProcsSorted())
+	if err != nil {
+		return err
+	}
+	exp.collectConnections()
+	for _, procName := range sortedStringMapKeys(exp.notExportable) {
+		Warning.Printf("[Process:%s] Not exportable to CWL: %s\n", procName, exp.notExportable[procName])
+	}
+
+	toolsDir := filepath.Join(dir, "tools")
+	err = os.MkdirAll(toolsDir, 0777)
+	if err != nil {
+		return fmt.Errorf("Could not create directory %s: %v", toolsDir, err)
+	}
+	for _, p := range exp.procs {
+		tool, err := newCWLTool(p)
+		if err != nil {
+			return err
+		}
+		err = writeCWLDoc(filepath.Join(toolsDir, p.Name()+".cwl"), tool)
+		if err != nil {
+			return err
+		}
+	}
+
+	cwlWf, err := exp.cwlWorkflow()
+	if err != nil {
+		return err
+	}
+	return writeCWLDoc(filepath.Join(dir, wf.Name()+".cwl"), cwlWf)
+}
+
+type cwlTool struct {
+	CWLVersion   string                   `yaml:"cwlVersion"`
+	Class        string                   `yaml:"class"`
+	Label        string                   `yaml:"label"`
+	Requirements map[string]interface{}   `yaml:"requirements"`
+	Arguments    []cwlArgument            `yaml:"arguments"`
+	Inputs       map[string]cwlToolInput  `yaml:"inputs"`
+	Outputs      map[string]cwlToolOutput `yaml:"outputs"`
+}
+
+type cwlArgument struct {
+	ValueFrom  string `yaml:"valueFrom"`
+	ShellQuote bool   `yaml:"shellQuote"`
+}
+
+type cwlToolInput struct {
+	Type string `yaml:"type"`
+}
+
+type cwlToolOutput struct {
+	Type          string           `yaml:"type"`
+	OutputBinding cwlOutputBinding `yaml:"outputBinding"`
+}
+
+type cwlOutputBinding struct {
+	Glob string `yaml:"glob"`
+}
+
+type cwlWorkflow struct {
+	CWLVersion string                       `yaml:"cwlVersion"`
+	Class      string                       `yaml:"class"`
+	Label      string                       `yaml:"label"`
+	Doc        string                       `yaml:"doc,omitempty"`
+	Inputs     map[string]string            `yaml:"inputs"`
+	Outputs    map[string]cwlWorkflowOutput `yaml:"outputs"`
+	Steps      map[string]cwlStep           `yaml:"steps"`
+}
+
+type cwlWorkflowOutput struct {
+	Type         string `yaml:"type"`
+	OutputSource string `yaml:"outputSource"`
+}
+
+type cwlStep struct {
+	Run string            `yaml:"run"`
+	In  map[string]string `yaml:"in"`
+	Out []string          `yaml:"out"`
+}
+
+// cwlExporter keeps track of the processes to export, and the connections
+// between them, while exporting a workflow to CWL
+type cwlExporter struct {
+	wf            *Workflow
+	procs         []*Process
+	notExportable map[string]string
+	// sources maps in-ports of exported processes to the "step/port" ids of
+	// the out-ports of exported processes they are connected to
+	sources map[*InPort][]string
+	// hasExportedTarget keeps track of out-ports connected to at least one
+	// exported process
+	hasExportedTarget map[*OutPort]bool
+}
+
+func newCWLExporter(wf *Workflow) *cwlExporter {
+	return &cwlExporter{
+		wf:                wf,
+		procs:             []*Process{},
+		notExportable:     map[string]string{},
+		sources:           map[*InPort][]string{},
+		hasExportedTarget: map[*OutPort]bool{},
+	}
+}
+
+// collectProcs sorts procs into exportable and not exportable processes,
+// recursing into sub-workflows
+func (exp *cwlExporter) collectProcs(procs []WorkflowProcess) error {
+	for _, proc := range procs {
+		if sw, ok := proc.(*SubWorkflow); ok {
+			err := exp.collectProcs(sw.ProcsSorted())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		reason := cwlNotExportableReason(proc)
+		if reason != "" {
+			exp.notExportable[proc.Name()] = reason
+			continue
+		}
+		p := proc.(*Process)
+		for _, other := range exp.procs {
+			if other.Name() == p.Name() {
+				return fmt.Errorf("[Process:%s] More than one process with the same name, which can not be exported to CWL", p.Name())
+			}
+		}
+		exp.procs = append(exp.procs, p)
+	}
+	return nil
+}
+
+// collectConnections records the connections between the exportable
+// processes, following connections through exposed ports of sub-workflows
+func (exp *cwlExporter) collectConnections() {
+	exported := map[WorkflowProcess]bool{}
+	for _, p := range exp.procs {
+		exported[p] = true
+	}
+	for _, p := range exp.procs {
+		for _, outName := range sortedOutPortMapKeys(p.OutPorts()) {
+			opt := p.OutPort(outName)
+			for _, rptName := range sortedInPortMapKeys(opt.RemotePorts) {
+				for _, ipt := range resolveInPorts(opt.RemotePorts[rptName]) {
+					if !exported[ipt.Process()] {
+						continue
+					}
+					exp.sources[ipt] = append(exp.sources[ipt], p.Name()+"/"+outName)
+					exp.hasExportedTarget[opt] = true
+				}
+			}
+		}
+	}
+}
+
+// cwlWorkflow returns the CWL workflow document wiring together the exported
+// processes
+func (exp *cwlExporter) cwlWorkflow() (*cwlWorkflow, error) {
+	cwlWf := &cwlWorkflow{
+		CWLVersion: cwlVersion,
+		Class:      "Workflow",
+		Label:      exp.wf.Name(),
+		Inputs:     map[string]string{},
+		Outputs:    map[string]cwlWorkflowOutput{},
+		Steps:      map[string]cwlStep{},
+	}
+	if len(exp.notExportable) > 0 {
+		cwlWf.Doc = "The following processes of the SciPipe workflow could not be exported to CWL, and need to be run separately:\n"
+		for _, procName := range sortedStringMapKeys(exp.notExportable) {
+			cwlWf.Doc += fmt.Sprintf("- %s: %s\n", procName, exp.notExportable[procName])
+		}
+	}
+
+	for _, p := range exp.procs {
+		step := cwlStep{
+			Run: "tools/" + p.Name() + ".cwl",
+			In:  map[string]string{},
+			Out: sortedOutPortMapKeys(p.OutPorts()),
+		}
+		for inName, ipt := range p.InPorts() {
+			sources := exp.sources[ipt]
+			switch len(sources) {
+			case 0:
+				inputID := p.Name() + "_" + inName
+				cwlWf.Inputs[inputID] = cwlInPortType(p, inName)
+				step.In[inName] = inputID
+			case 1:
+				step.In[inName] = sources[0]
+			default:
+				return nil, fmt.Errorf("[Process:%s] In-port (%s) is connected to more than one out-port (%s), which can not be exported to CWL", p.Name(), inName, strings.Join(sources, ", "))
+			}
+		}
+		for paramName := range p.InParamPorts() {
+			inputID := p.Name() + "_" + paramName
+			cwlWf.Inputs[inputID] = "string"
+			step.In[paramName] = inputID
+		}
+		for outName, opt := range p.OutPorts() {
+			if !exp.hasExportedTarget[opt] {
+				cwlWf.Outputs[p.Name()+"_"+outName] = cwlWorkflowOutput{
+					Type:         "File",
+					OutputSource: p.Name() + "/" + outName,
+				}
+			}
+		}
+		cwlWf.Steps[p.Name()] = step
+	}
+	return cwlWf, nil
+}
+
+// cwlNotExportableReason returns the reason why proc can not be exported to
+// CWL, or an empty string if it can
+func cwlNotExportableReason(proc WorkflowProcess) string {
+	p, ok := proc.(*Process)
+	if !ok {
+		return fmt.Sprintf("Custom Go process (of type %T)", proc)
+	}
+	if p.CustomExecute != nil {
+		return "Process uses a CustomExecute function"
+	}
+	if p.CombineMode != CombineZip || len(p.broadcastPorts) > 0 {
+		return "Process combines its inputs in other ways than one-to-one (CombineMode or Broadcast)"
+	}
+	for _, outName := range sortedOutPortMapKeys(p.OutPorts()) {
+		if _, ok := p.outPathPatterns[outName]; !ok && p.customPathFuncs[outName] {
+			return fmt.Sprintf("Out-port (%s) uses a custom path function", outName)
+		}
+	}
+	r := getShellCommandPlaceHolderRegex()
+	for _, ptn := range append([]string{p.CommandPattern}, p.cwlOutPathPatterns()...) {
+		for _, m := range r.FindAllStringSubmatch(ptn, -1) {
+			if m[1] == "t" {
+				return fmt.Sprintf("Process uses tags (%s)", m[0])
+			}
+		}
+	}
+	return ""
+}
+
+// ----------------------------------------------------------------------------
+// CWL CommandLineTool
+// ----------------------------------------------------------------------------
+
+// newCWLTool returns a CWL CommandLineTool document for the process p
+func newCWLTool(p *Process) (*cwlTool, error) {
+	tool := &cwlTool{
+		CWLVersion: cwlVersion,
+		Class:      "CommandLineTool",
+		Label:      p.Name(),
+		Requirements: map[string]interface{}{
+			"ShellCommandRequirement":     map[string]interface{}{},
+			"InlineJavascriptRequirement": map[string]interface{}{},
+			"ResourceRequirement":         map[string]int{"coresMin": p.CoresPerTask},
+		},
+		Inputs:  map[string]cwlToolInput{},
+		Outputs: map[string]cwlToolOutput{},
+	}
+
+	for inName := range p.InPorts() {
+		tool.Inputs[inName] = cwlToolInput{Type: cwlInPortType(p, inName)}
+	}
+	for paramName := range p.InParamPorts() {
+		tool.Inputs[paramName] = cwlToolInput{Type: "string"}
+	}
+
+	cmd := ""
+	for _, outName := range sortedOutPortMapKeys(p.OutPorts()) {
+		pathExpr, err := p.cwlOutPathExpr(outName, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		tool.Outputs[outName] = cwlToolOutput{
+			Type:          "File",
+			OutputBinding: cwlOutputBinding{Glob: "$(" + pathExpr + ")"},
+		}
+		// Create any folders in the output path, like SciPipe does
+		if strings.Contains(p.cwlOutPathPattern(outName), "/") {
+			cmd += "mkdir -p $(" + pathExpr + `.replace(/\/[^\/]*$/, "")) && `
+		}
+	}
+
+	cmdExpr, err := p.cwlCommand()
+	if err != nil {
+		return nil, err
+	}
+	tool.Arguments = []cwlArgument{{ValueFrom: cmd + cmdExpr, ShellQuote: false}}
+	return tool, nil
+}
+
+// cwlInPortType returns the CWL type for the in-port inName of p
+func cwlInPortType(p *Process, inName string) string {
+	if pInfo, ok := p.PortInfo[inName]; ok && pInfo.join {
+		return "File[]"
+	}
+	return "File"
+}
+
+// cwlOutPathPatterns returns the path patterns for all out-ports of p
+func (p *Process) cwlOutPathPatterns() []string {
+	ptns := []string{}
+	for _, outName := range sortedOutPortMapKeys(p.OutPorts()) {
+		ptns = append(ptns, p.cwlOutPathPattern(outName))
+	}
+	return ptns
+}
+
+// cwlOutPathPattern returns the path pattern for the out-port outName, which
+// is either the one set with SetOut, or a pattern corresponding to the default
+// path function (except for tags, which are not exported)
+func (p *Process) cwlOutPathPattern(outName string) string {
+	if ptn, ok := p.outPathPatterns[outName]; ok {
+		return ptn
+	}
+	pathPcs := []string{}
+	for _, inName := range sortedInPortMapKeys(p.InPorts()) {
+		pathPcs = append(pathPcs, "{i:"+inName+"}")
+	}
+	pathPcs = append(pathPcs, sanitizePathFragment(p.Name()))
+	for _, paramName := range sortedInParamPortMapKeys(p.InParamPorts()) {
+		pathPcs = append(pathPcs, paramName+"_{p:"+paramName+"}")
+	}
+	pathPcs = append(pathPcs, outName)
+	if pInfo, ok := p.PortInfo[outName]; ok && pInfo.extension != "" {
+		pathPcs = append(pathPcs, pInfo.extension)
+	}
+	return strings.Join(pathPcs, ".")
+}
+
+// cwlOutPathExpr returns a JavaScript expression producing the path of the
+// out-port outName. Paths of in-ports are replaced with their base names, so
+// that outputs end up in the output folder of the CWL step.
+func (p *Process) cwlOutPathExpr(outName string, visited map[string]bool) (string, error) {
+	if visited[outName] {
+		return "", fmt.Errorf("[Process:%s] Path pattern for out-port (%s) refers to itself", p.Name(), outName)
+	}
+	visited[outName] = true
+	defer delete(visited, outName)
+
+	pcs, err := p.cwlPatternPieces(p.cwlOutPathPattern(outName), true, visited)
+	if err != nil {
+		return "", err
+	}
+	exprs := []string{}
+	for _, pc := range pcs {
+		if pc.isExpr {
+			exprs = append(exprs, "("+pc.text+")")
+		} else {
+			exprs = append(exprs, strconv.Quote(pc.text))
+		}
+	}
+	if len(exprs) == 0 {
+		return `""`, nil
+	}
+	return strings.Join(exprs, " + "), nil
+}
+
+// cwlCommand returns the command pattern of p, with placeholders replaced by
+// CWL parameter references (JavaScript expressions in $(...)), and with any $(
+// and ${ in the rest of the command escaped, so that CWL does not interpret
+// them as expressions
+func (p *Process) cwlCommand() (string, error) {
+	pcs, err := p.cwlPatternPieces(p.CommandPattern, false, map[string]bool{})
+	if err != nil {
+		return "", err
+	}
+	cmd := ""
+	for _, pc := range pcs {
+		if pc.isExpr {
+			cmd += "$(" + pc.text + ")"
+		} else {
+			cmd += strings.Replace(strings.Replace(pc.text, "$(", `\$(`, -1), "${", `\${`, -1)
+		}
+	}
+	return cmd, nil
+}
+
+// cwlPatternPiece is either a literal piece of text, or a JavaScript
+// expression, in a command or path pattern
+type cwlPatternPiece struct {
+	text   string
+	isExpr bool
+}
+
+// cwlPatternPieces splits ptn into literal text and JavaScript expressions
+// corresponding to the placeholders in the pattern. If inPathPattern is true,
+// in-port placeholders are replaced with the base names of the files rather
+// than the full paths.
+func (p *Process) cwlPatternPieces(ptn string, inPathPattern bool, visited map[string]bool) ([]cwlPatternPiece, error) {
+	r := getShellCommandPlaceHolderRegex()
+	pcs := []cwlPatternPiece{}
+	lastEnd := 0
+	for _, loc := range r.FindAllStringSubmatchIndex(ptn, -1) {
+		if loc[0] > lastEnd {
+			pcs = append(pcs, cwlPatternPiece{text: ptn[lastEnd:loc[0]]})
+		}
+		lastEnd = loc[1]
+
+		placeHolder := ptn[loc[0]:loc[1]]
+		phType := ptn[loc[2]:loc[3]]
+		parts := strings.Split(ptn[loc[4]:loc[5]], "|")
+		portName, modifiers := parts[0], parts[1:]
+
+		var expr string
+		switch phType {
+		case "i":
+			if _, ok := p.InPorts()[portName]; !ok {
+				return nil, fmt.Errorf("[Process:%s] Placeholder (%s) refers to non-existing in-port", p.Name(), placeHolder)
+			}
+			prop := "path"
+			if inPathPattern {
+				prop = "basename"
+			}
+			if pInfo := p.PortInfo[portName]; pInfo != nil && pInfo.join && !inPathPattern {
+				expr = fmt.Sprintf("inputs.%s.map(function(f) { return %s; }).join(%s)", portName, cwlModifiersExpr("f."+prop, modifiers), strconv.Quote(pInfo.joinSep))
+			} else {
+				expr = cwlModifiersExpr("inputs."+portName+"."+prop, modifiers)
+			}
+		case "o", "os":
+			if _, ok := p.OutPorts()[portName]; !ok {
+				return nil, fmt.Errorf("[Process:%s] Placeholder (%s) refers to non-existing out-port", p.Name(), placeHolder)
+			}
+			pathExpr, err := p.cwlOutPathExpr(portName, visited)
+			if err != nil {
+				return nil, err
+			}
+			expr = cwlModifiersExpr("("+pathExpr+")", modifiers)
+			if expr == "("+pathExpr+")" {
+				expr = pathExpr
+			}
+		case "p":
+			if _, ok := p.InParamPorts()[portName]; !ok {
+				return nil, fmt.Errorf("[Process:%s] Placeholder (%s) refers to non-existing param in-port", p.Name(), placeHolder)
+			}
+			expr = cwlModifiersExpr("inputs."+portName, modifiers)
+		default:
+			return nil, fmt.Errorf("[Process:%s] Placeholder (%s) can not be exported to CWL", p.Name(), placeHolder)
+		}
+		pcs = append(pcs, cwlPatternPiece{text: expr, isExpr: true})
+	}
+	if lastEnd < len(ptn) {
+		pcs = append(pcs, cwlPatternPiece{text: ptn[lastEnd:]})
+	}
+	return pcs, nil
+}
+
+// cwlModifiersExpr returns a JavaScript expression applying the path
+// modifiers (see applyPathModifiers) to the value of the expression expr
+func cwlModifiersExpr(expr string, modifiers []string) string {
+	substPtn := regexp.MustCompile("s\\/([^\\/]+)\\/([^\\/]*)\\/")
+	trimEndPtn := regexp.MustCompile("%(.*)")
+	for _, modifier := range modifiers {
+		if substPtn.MatchString(modifier) {
+			mbits := substPtn.FindStringSubmatch(modifier)
+			expr += fmt.Sprintf(".replace(%s, %s)", strconv.Quote(mbits[1]), strconv.Quote(mbits[2]))
+		}
+		if trimEndPtn.MatchString(modifier) {
+			mbits := trimEndPtn.FindStringSubmatch(modifier)
+			expr += fmt.Sprintf(".replace(new RegExp(%s), \"\")", strconv.Quote(regexp.QuoteMeta(mbits[1])+"$"))
+		}
+		switch modifier {
+		case "basename":
+			expr += `.replace(/^.*\//, "")`
+		case "dirname":
+			expr += `.replace(/\/[^\/]*$/, "")`
+		}
+	}
+	return expr
+}
+
+// writeCWLDoc writes the CWL document doc as YAML to filePath
+func writeCWLDoc(filePath string, doc interface{}) error {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	err := enc.Encode(doc)
+	if err != nil {
+		return fmt.Errorf("Could not marshal CWL document %s: %v", filePath, err)
+	}
+	err = ioutil.WriteFile(filePath, buf.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("Could not write CWL document %s: %v", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package scipipe
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExportCWL(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("cwlwf", 4)
+
+	src := NewFileSource(wf, "src", "foo.txt")
+
+	f2b := wf.NewProc("f2b", "sed 's/foo/{p:repl}/g' {i:in} > {o:out}")
+	f2b.SetOut("out", "{i:in|%.txt}.{p:repl}.txt")
+	f2b.In("in").From(src.Out())
+	f2b.InParam("repl").FromStr("bar")
+
+	cat := wf.NewProc("cat", "cat {i:in} > {o:out|.txt} # $(date)")
+	cat.CoresPerTask = 2
+	cat.In("in").From(f2b.Out("out"))
+
+	dir := ".tmp/cwl"
+	err := wf.ExportCWL(dir)
+	if err != nil {
+		t.Fatalf("Could not export workflow to CWL: %v", err)
+	}
+
+	cwlWf := &cwlWorkflow{}
+	readCWLDoc(t, dir+"/cwlwf.cwl", cwlWf)
+	assertEqualValues(t, "Workflow", cwlWf.Class)
+	if !strings.Contains(cwlWf.Doc, "- src: Custom Go process (of type *scipipe.FileSource)") {
+		t.Errorf("Expected non-exportable process src to be listed in workflow doc, but got: %s", cwlWf.Doc)
+	}
+	assertEqualValues(t, map[string]string{"f2b_in": "File", "f2b_repl": "string"}, cwlWf.Inputs)
+	assertEqualValues(t, map[string]cwlWorkflowOutput{"cat_out": {Type: "File", OutputSource: "cat/out"}}, cwlWf.Outputs)
+	assertEqualValues(t, map[string]cwlStep{
+		"f2b": {Run: "tools/f2b.cwl", In: map[string]string{"in": "f2b_in", "repl": "f2b_repl"}, Out: []string{"out"}},
+		"cat": {Run: "tools/cat.cwl", In: map[string]string{"in": "f2b/out"}, Out: []string{"out"}},
+	}, cwlWf.Steps)
+
+	f2bPathExpr := `(inputs.in.basename.replace(new RegExp("\\.txt$"), "")) + "." + (inputs.repl) + ".txt"`
+	f2bTool := &cwlTool{}
+	readCWLDoc(t, dir+"/tools/f2b.cwl", f2bTool)
+	assertEqualValues(t, "CommandLineTool", f2bTool.Class)
+	assertEqualValues(t, "sed 's/foo/$(inputs.repl)/g' $(inputs.in.path) > $("+f2bPathExpr+")", f2bTool.Arguments[0].ValueFrom)
+	assertEqualValues(t, "$("+f2bPathExpr+")", f2bTool.Outputs["out"].OutputBinding.Glob)
+	assertEqualValues(t, map[string]cwlToolInput{"in": {Type: "File"}, "repl": {Type: "string"}}, f2bTool.Inputs)
+
+	catTool := &cwlTool{}
+	readCWLDoc(t, dir+"/tools/cat.cwl", catTool)
+	assertEqualValues(t, `cat $(inputs.in.path) > $((inputs.in.basename) + ".cat.out.txt") # \$(date)`, catTool.Arguments[0].ValueFrom)
+	assertEqualValues(t, map[string]interface{}{"coresMin": 2}, catTool.Requirements["ResourceRequirement"])
+
+	os.RemoveAll(dir)
+}
+
+func TestExportCWLNotExportable(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("cwlwf", 4)
+
+	custom := wf.NewProc("custom", "echo {o:out}")
+	custom.CustomExecute = func(tk *Task) {}
+	funcs := wf.NewProc("funcs", "echo foo > {o:out}")
+	funcs.SetOutFunc("out", func(tk *Task) string { return "foo.txt" })
+	tags := wf.NewProc("tags", "echo {t:sample} > {o:out}")
+	ok := wf.NewProc("ok", "echo foo > {o:out}")
+
+	for proc, expectedReason := range map[WorkflowProcess]string{
+		custom: "Process uses a CustomExecute function",
+		funcs:  "Out-port (out) uses a custom path function",
+		tags:   "Process uses tags ({t:sample})",
+		ok:     "",
+	} {
+		reason := cwlNotExportableReason(proc)
+		if reason != expectedReason {
+			t.Errorf("Wrong reason for process %s not being exportable. Expected: '%s', Got: '%s'", proc.Name(), expectedReason, reason)
+		}
+	}
+}
+
+func readCWLDoc(t *testing.T, filePath string, doc interface{}) {
+	dat, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Could not read exported CWL file: %s", filePath)
+	}
+	err = yaml.Unmarshal(dat, doc)
+	if err != nil {
+		t.Fatalf("Could not parse exported CWL file %s: %v", filePath, err)
+	}
+}
@@ -9,7 +9,12 @@ func main() {
 	wfl := sp.NewWorkflow("foobar_wf", 4)
 
 	// Sub-workflow
-	NewFooBarSubWorkflow(wfl, "foobar_subwf")
+	foobar := NewFooBarSubWorkflow(wfl, "foobar_subwf")
+
+	// Process using the output of the sub-workflow
+	cat := wfl.NewProc("cat", "cat {i:in} > {o:out}")
+	cat.SetOut("out", "{i:in|%.txt}.cat.txt")
+	cat.In("in").From(foobar.Out("bar"))
 
 	// Run
 	wfl.Run()
@@ -19,42 +24,22 @@ func main() {
 // FooBarSubWorkflow
 // ------------------------------------------------
 
-type FooBarSubWorkflow struct {
-	name  string
-	Procs map[string]*sp.Process
-	Out   *sp.OutPort
-}
-
-func NewFooBarSubWorkflow(wf *sp.Workflow, name string) *FooBarSubWorkflow {
-	fbn := &FooBarSubWorkflow{
-		name:  name,
-		Procs: make(map[string]*sp.Process),
-	}
+// NewFooBarSubWorkflow returns a sub-workflow that writes "foo" to a file, and
+// replaces it with "bar", exposing the resulting file on the out-port "bar"
+func NewFooBarSubWorkflow(wf *sp.Workflow, name string) *sp.SubWorkflow {
+	sw := sp.NewSubWorkflow(wf, name)
 
-	fbn.Procs["foo"] = sp.NewProc(wf, "foo", "echo foo > {o:foo}")
-	fbn.Procs["foo"].SetOut("foo", "foo.txt")
+	foo := sw.NewProc("foo", "echo foo > {o:foo}")
+	foo.SetOut("foo", "foo.txt")
 
-	fbn.Procs["f2b"] = sp.NewProc(wf, "f2b", "sed 's/foo/bar/g' {i:foo} > {o:bar}")
-	fbn.Procs["f2b"].SetOut("bar", "{i:foo|%.txt}.bar.txt")
+	f2b := sw.NewProc("f2b", "sed 's/foo/bar/g' {i:foo} > {o:bar}")
+	f2b.SetOut("bar", "{i:foo|%.txt}.bar.txt")
 
 	// Connect together inner processes
-	fbn.Procs["foo"].Out("foo").To(fbn.Procs["f2b"].In("foo"))
-
-	// Connect last port of inner process to subnetwork out-port
-	fbn.Out = fbn.Procs["f2b"].Out("bar")
-	return fbn
-}
-
-func (wf *FooBarSubWorkflow) Name() string {
-	return wf.name
-}
-
-func (wf *FooBarSubWorkflow) Run() {
-	for _, proc := range wf.Procs {
-		go proc.Run()
-	}
-}
+	foo.Out("foo").To(f2b.In("foo"))
 
-func (wf *FooBarSubWorkflow) Ready() bool {
-	return wf.Out.Ready()
+	// Expose the out-port of the last inner process as an out-port of the
+	// sub-workflow
+	sw.ExposeOut("bar", f2b.Out("bar"))
+	return sw
 }
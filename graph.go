@@ -0,0 +1,462 @@
+package scipipe
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Graph information shared by the DOT, Mermaid and SVG graph formats
+// ----------------------------------------------------------------------------
+
+// graphEdge is a connection between two ports, as drawn in workflow graphs
+type graphEdge struct {
+	fromProc string
+	fromPort string
+	toProc   string
+	toPort   string
+	isParam  bool
+	// The ports directly connected by the edge, used to count the IPs passing
+	outPort         *OutPort
+	remotePort      *InPort
+	outParamPort    *OutParamPort
+	remoteParamPort *InParamPort
+}
+
+// sentCount returns the number of IPs (or parameters) sent over the edge
+func (e graphEdge) sentCount() int {
+	if e.isParam {
+		return e.outParamPort.sentCount(e.remoteParamPort)
+	}
+	return e.outPort.sentCount(e.remotePort)
+}
+
+// graphEdges returns the edges for the out-ports and param out-ports of p. For
+// sub-workflows, the edges of the inner processes are returned, and
+// connections via exposed ports are resolved to the inner processes.
+func graphEdges(p WorkflowProcess) []graphEdge {
+	edges := []graphEdge{}
+	if sw, ok := p.(*SubWorkflow); ok {
+		for _, inner := range sw.ProcsSorted() {
+			edges = append(edges, graphEdges(inner)...)
+		}
+		return edges
+	}
+	remToDotPtn := regexp.MustCompile(`^.*\.`)
+	// File connections
+	for _, opName := range sortedOutPortMapKeys(p.OutPorts()) {
+		opt := p.OutPorts()[opName]
+		for _, rptName := range sortedInPortMapKeys(opt.RemotePorts) {
+			for _, rpt := range resolveInPorts(opt.RemotePorts[rptName]) {
+				edges = append(edges, graphEdge{
+					fromProc:   opt.Process().Name(),
+					fromPort:   remToDotPtn.ReplaceAllString(opName, ""),
+					toProc:     rpt.Process().Name(),
+					toPort:     remToDotPtn.ReplaceAllString(rpt.Name(), ""),
+					outPort:    opt,
+					remotePort: opt.RemotePorts[rptName],
+				})
+			}
+		}
+	}
+	// Parameter connections
+	for _, popName := range sortedOutParamPortMapKeys(p.OutParamPorts()) {
+		pop := p.OutParamPorts()[popName]
+		for _, rpName := range sortedInParamPortMapKeys(pop.RemotePorts) {
+			for _, rp := range resolveInParamPorts(pop.RemotePorts[rpName]) {
+				edges = append(edges, graphEdge{
+					fromProc:        pop.Process().Name(),
+					fromPort:        remToDotPtn.ReplaceAllString(popName, ""),
+					toProc:          rp.Process().Name(),
+					toPort:          remToDotPtn.ReplaceAllString(rp.Name(), ""),
+					isParam:         true,
+					outParamPort:    pop,
+					remoteParamPort: pop.RemotePorts[rpName],
+				})
+			}
+		}
+	}
+	return edges
+}
+
+// ----------------------------------------------------------------------------
+// Mermaid
+// ----------------------------------------------------------------------------
+
+// MermaidGraph generates a graph description in the Mermaid flowchart format
+// (See https://mermaid-js.github.io), suitable for embedding in markdown
+// documents. Like for DotGraph, port names are added as edge labels if
+// Workflow.PlotConf.EdgeLabels is set to true, parameter connections are drawn
+// dashed, and sub-workflows are drawn as subgraphs.
+func (wf *Workflow) MermaidGraph() (mmd string) {
+	ids := map[string]string{}
+	mermaidID := func(name string) string {
+		if _, ok := ids[name]; !ok {
+			ids[name] = fmt.Sprintf("p%d", len(ids))
+		}
+		return ids[name]
+	}
+
+	mmd = "flowchart LR\n"
+	edges := []graphEdge{}
+	for _, p := range wf.ProcsSorted() {
+		mmd += wf.mermaidNodes(p, "  ", mermaidID)
+		edges = append(edges, graphEdges(p)...)
+	}
+	for _, e := range edges {
+		arrow := "-->"
+		if e.isParam {
+			arrow = "-.->"
+		}
+		if wf.PlotConf.EdgeLabels {
+			arrow += fmt.Sprintf(`|"%s"|`, mermaidEscape(e.fromPort+" → "+e.toPort))
+		}
+		mmd += fmt.Sprintf("  %s %s %s\n", mermaidID(e.fromProc), arrow, mermaidID(e.toProc))
+	}
+	return mmd
+}
+
+// mermaidNodes returns the Mermaid node definition for the process p, or a
+// subgraph with the inner processes, if p is a sub-workflow
+func (wf *Workflow) mermaidNodes(p WorkflowProcess, indent string, mermaidID func(string) string) (mmd string) {
+	sw, ok := p.(*SubWorkflow)
+	if !ok {
+		return fmt.Sprintf("%s%s[\"%s\"]\n", indent, mermaidID(p.Name()), mermaidEscape(p.Name()))
+	}
+	mmd = fmt.Sprintf("%ssubgraph %s[\"%s\"]\n", indent, mermaidID(sw.Name()), mermaidEscape(sw.Name()))
+	for _, inner := range sw.ProcsSorted() {
+		mmd += wf.mermaidNodes(inner, indent+"  ", mermaidID)
+	}
+	mmd += indent + "end\n"
+	return mmd
+}
+
+func mermaidEscape(s string) string {
+	return strings.Replace(s, `"`, "#quot;", -1)
+}
+
+// ----------------------------------------------------------------------------
+// SVG
+// ----------------------------------------------------------------------------
+
+// PlotGraphSVG writes the workflow structure as an SVG image to filePath,
+// without requiring graphviz to be installed (See SVGGraph)
+func (wf *Workflow) PlotGraphSVG(filePath string) {
+	svg := wf.SVGGraph()
+	createDirs(filePath)
+	svgFile, err := os.Create(filePath)
+	CheckWithMsg(err, "Could not create SVG file "+filePath)
+	defer svgFile.Close()
+	_, errSVG := svgFile.WriteString(svg)
+	if errSVG != nil {
+		wf.Failf("Could not write to SVG-file %s: %s", svgFile.Name(), errSVG)
+	}
+}
+
+const (
+	svgMargin     = 40
+	svgNodeHeight = 32
+	svgNodePadX   = 14
+	svgCharWidth  = 7
+	svgLayerGap   = 100
+	svgRowGap     = 28
+	svgClusterPad = 14
+	svgColor      = "#384A52"
+	svgFillColor  = "#EFF2F5"
+)
+
+// svgNode is a process, positioned in the layered layout of an SVG graph
+type svgNode struct {
+	name     string
+	clusters []string
+	layer    int
+	order    int
+	x, y     int
+	width    int
+}
+
+// SVGGraph draws the workflow as an SVG image, using a simple layered layout
+// computed in pure Go, so that graphviz does not need to be installed.
+// Processes are placed in layers from left to right, following the direction
+// of the connections, and ordered within layers so as to reduce crossing
+// edges. Like for DotGraph, port names are drawn at the ends of edges if
+// Workflow.PlotConf.EdgeLabels is set to true, parameter connections are drawn
+// dashed, and sub-workflows are drawn as boxes around their inner processes.
+func (wf *Workflow) SVGGraph() string {
+	nodes := map[string]*svgNode{}
+	names := []string{}
+	var addNodes func(procs []WorkflowProcess, clusters []string)
+	addNodes = func(procs []WorkflowProcess, clusters []string) {
+		for _, p := range procs {
+			if sw, ok := p.(*SubWorkflow); ok {
+				addNodes(sw.ProcsSorted(), append(append([]string{}, clusters...), sw.Name()))
+				continue
+			}
+			nodes[p.Name()] = &svgNode{name: p.Name(), clusters: clusters}
+			names = append(names, p.Name())
+		}
+	}
+	addNodes(wf.ProcsSorted(), []string{})
+
+	edges := []graphEdge{}
+	for _, p := range wf.ProcsSorted() {
+		edges = append(edges, graphEdges(p)...)
+	}
+	// Processes not part of the workflow, such as sinks, are drawn too, like
+	// graphviz does for nodes only mentioned in edges
+	for _, e := range edges {
+		for _, name := range []string{e.fromProc, e.toProc} {
+			if _, ok := nodes[name]; !ok {
+				nodes[name] = &svgNode{name: name}
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	layers := svgAssignLayers(names, nodes, edges)
+	svgOrderLayers(layers, edges, nodes)
+	width, height := svgPositionNodes(layers)
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Arial">`+"\n", width, height, width, height)
+	svg += fmt.Sprintf("  <title>%s</title>\n", html.EscapeString(wf.Name()))
+	svg += "  <defs>\n"
+	svg += fmt.Sprintf(`    <marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M 0 0 L 10 5 L 0 10 z" fill="%s"/></marker>`+"\n", svgColor)
+	svg += "  </defs>\n"
+	svg += svgClusters(names, nodes)
+	for _, e := range edges {
+		svg += wf.svgEdge(e, nodes[e.fromProc], nodes[e.toProc])
+	}
+	for _, name := range names {
+		n := nodes[name]
+		svg += fmt.Sprintf(`  <rect x="%d" y="%d" width="%d" height="%d" fill="%s" stroke="%s"/>`+"\n", n.x, n.y, n.width, svgNodeHeight, svgFillColor, svgColor)
+		svg += fmt.Sprintf(`  <text x="%d" y="%d" font-size="11" fill="%s" text-anchor="middle">%s</text>`+"\n", n.x+n.width/2, n.y+svgNodeHeight/2+4, svgColor, html.EscapeString(n.name))
+	}
+	svg += "</svg>\n"
+	return svg
+}
+
+// svgAssignLayers assigns each node to the layer given by the longest path to
+// it from any node without incoming edges, ignoring edges closing cycles, and
+// returns the nodes in each layer
+func svgAssignLayers(names []string, nodes map[string]*svgNode, edges []graphEdge) [][]*svgNode {
+	succs := map[string][]string{}
+	for _, e := range edges {
+		succs[e.fromProc] = append(succs[e.fromProc], e.toProc)
+	}
+
+	// Find a topological order with depth-first search, skipping back edges
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := map[string]int{}
+	order := []string{}
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = onStack
+		for _, succ := range succs[name] {
+			if state[succ] == unvisited {
+				visit(succ)
+			}
+		}
+		state[name] = done
+		order = append(order, name)
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	position := map[string]int{}
+	for i, name := range order {
+		position[name] = len(order) - 1 - i
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		name := order[i]
+		for _, succ := range succs[name] {
+			isForward := position[succ] > position[name]
+			if isForward && nodes[succ].layer < nodes[name].layer+1 {
+				nodes[succ].layer = nodes[name].layer + 1
+			}
+		}
+	}
+
+	layers := [][]*svgNode{}
+	for _, name := range names {
+		n := nodes[name]
+		for len(layers) <= n.layer {
+			layers = append(layers, []*svgNode{})
+		}
+		n.order = len(layers[n.layer])
+		layers[n.layer] = append(layers[n.layer], n)
+	}
+	return layers
+}
+
+// svgOrderLayers orders the nodes within each layer by the average position
+// of their neighbours in the adjacent layers (the "barycenter" heuristic),
+// sweeping back and forth a few times, to reduce the number of crossing edges
+func svgOrderLayers(layers [][]*svgNode, edges []graphEdge, nodes map[string]*svgNode) {
+	preds := map[*svgNode][]*svgNode{}
+	succs := map[*svgNode][]*svgNode{}
+	for _, e := range edges {
+		from, to := nodes[e.fromProc], nodes[e.toProc]
+		preds[to] = append(preds[to], from)
+		succs[from] = append(succs[from], to)
+	}
+
+	sortLayer := func(layer []*svgNode, neighbours map[*svgNode][]*svgNode, neighbourLayer int) {
+		barycenters := map[*svgNode]float64{}
+		for _, n := range layer {
+			sum, cnt := 0.0, 0
+			for _, nb := range neighbours[n] {
+				if nb.layer == neighbourLayer {
+					sum += float64(nb.order)
+					cnt++
+				}
+			}
+			if cnt > 0 {
+				barycenters[n] = sum / float64(cnt)
+			} else {
+				barycenters[n] = float64(n.order)
+			}
+		}
+		sort.SliceStable(layer, func(i, j int) bool {
+			return barycenters[layer[i]] < barycenters[layer[j]]
+		})
+		for i, n := range layer {
+			n.order = i
+		}
+	}
+
+	for sweep := 0; sweep < 4; sweep++ {
+		for i := 1; i < len(layers); i++ {
+			sortLayer(layers[i], preds, i-1)
+		}
+		for i := len(layers) - 2; i >= 0; i-- {
+			sortLayer(layers[i], succs, i+1)
+		}
+	}
+}
+
+// svgPositionNodes sets the coordinates of the nodes, with layers as columns
+// from left to right, each one centered vertically, and returns the total
+// width and height of the image
+func svgPositionNodes(layers [][]*svgNode) (width int, height int) {
+	maxRows := 0
+	for _, layer := range layers {
+		if len(layer) > maxRows {
+			maxRows = len(layer)
+		}
+	}
+	height = 2*svgMargin + maxRows*svgNodeHeight + (maxRows-1)*svgRowGap
+	if maxRows == 0 {
+		height = 2 * svgMargin
+	}
+
+	x := svgMargin
+	for i, layer := range layers {
+		if i > 0 {
+			x += svgLayerGap
+		}
+		layerWidth := 0
+		for _, n := range layer {
+			n.width = len(n.name)*svgCharWidth + 2*svgNodePadX
+			if n.width > layerWidth {
+				layerWidth = n.width
+			}
+		}
+		offset := (maxRows - len(layer)) * (svgNodeHeight + svgRowGap) / 2
+		for _, n := range layer {
+			n.x = x + (layerWidth-n.width)/2
+			n.y = svgMargin + offset + n.order*(svgNodeHeight+svgRowGap)
+		}
+		x += layerWidth
+	}
+	width = x + svgMargin
+	return width, height
+}
+
+// svgClusters returns boxes drawn around the inner processes of each
+// sub-workflow, with outer sub-workflows drawn first, so that inner ones end
+// up on top
+func svgClusters(names []string, nodes map[string]*svgNode) (svg string) {
+	type bbox struct{ x1, y1, x2, y2, depth int }
+	boxes := map[string]*bbox{}
+	clusterNames := []string{}
+	for _, name := range names {
+		n := nodes[name]
+		for depth, cName := range n.clusters {
+			b, ok := boxes[cName]
+			if !ok {
+				b = &bbox{x1: n.x, y1: n.y, x2: n.x + n.width, y2: n.y + svgNodeHeight, depth: depth}
+				boxes[cName] = b
+				clusterNames = append(clusterNames, cName)
+			}
+			b.x1 = minInt(b.x1, n.x)
+			b.y1 = minInt(b.y1, n.y)
+			b.x2 = maxInt(b.x2, n.x+n.width)
+			b.y2 = maxInt(b.y2, n.y+svgNodeHeight)
+		}
+	}
+	// Make sure that boxes of outer sub-workflows enclose the inner ones
+	for _, name := range names {
+		clusters := nodes[name].clusters
+		for i := len(clusters) - 1; i > 0; i-- {
+			inner, outer := boxes[clusters[i]], boxes[clusters[i-1]]
+			outer.x1 = minInt(outer.x1, inner.x1-svgClusterPad)
+			outer.y1 = minInt(outer.y1, inner.y1-svgClusterPad-12)
+			outer.x2 = maxInt(outer.x2, inner.x2+svgClusterPad)
+			outer.y2 = maxInt(outer.y2, inner.y2+svgClusterPad)
+		}
+	}
+	sort.SliceStable(clusterNames, func(i, j int) bool {
+		return boxes[clusterNames[i]].depth < boxes[clusterNames[j]].depth
+	})
+	for _, cName := range clusterNames {
+		b := boxes[cName]
+		x, y := b.x1-svgClusterPad, b.y1-svgClusterPad-12
+		svg += fmt.Sprintf(`  <rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s"/>`+"\n", x, y, b.x2-b.x1+2*svgClusterPad, b.y2-b.y1+2*svgClusterPad+12, svgColor)
+		svg += fmt.Sprintf(`  <text x="%d" y="%d" font-size="13" fill="%s">%s</text>`+"\n", x+6, y+15, svgColor, html.EscapeString(cName))
+	}
+	return svg
+}
+
+// svgEdge returns the SVG path (and labels) for the edge e, from the right
+// side of the from node, to the left side of the to node
+func (wf *Workflow) svgEdge(e graphEdge, from *svgNode, to *svgNode) (svg string) {
+	x1, y1 := from.x+from.width, from.y+svgNodeHeight/2
+	x2, y2 := to.x, to.y+svgNodeHeight/2
+	dx := maxInt(40, (x2-x1)/2)
+	dash := ""
+	if e.isParam {
+		dash = ` stroke-dasharray="5,3"`
+	}
+	svg += fmt.Sprintf(`  <path d="M %d %d C %d %d, %d %d, %d %d" fill="none" stroke="%s"%s marker-end="url(#arrow)"/>`+"\n", x1, y1, x1+dx, y1, x2-dx, y2, x2, y2, svgColor, dash)
+	if wf.PlotConf.EdgeLabels {
+		svg += fmt.Sprintf(`  <text x="%d" y="%d" font-size="9" fill="%s" text-anchor="start">%s</text>`+"\n", x1+4, y1-4, svgColor, html.EscapeString(e.fromPort))
+		svg += fmt.Sprintf(`  <text x="%d" y="%d" font-size="9" fill="%s" text-anchor="end">%s</text>`+"\n", x2-4, y2-4, svgColor, html.EscapeString(e.toPort))
+	}
+	return svg
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
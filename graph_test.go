@@ -0,0 +1,102 @@
+package scipipe
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMermaidGraph(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("testwf", 4)
+
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	params := NewParamSource(wf, "params", "a", "b")
+	sw := newFooToBazSubWorkflow(wf, "foo2baz")
+	sw.In("in").From(foo.Out("out"))
+	cat := wf.NewProc("cat", "cat {i:in} > {o:out} # {p:note}")
+	cat.In("in").From(sw.Out("out"))
+	cat.InParam("note").From(params.Out())
+
+	expected := `flowchart LR
+  p0["cat"]
+  p1["foo"]
+  subgraph p2["foo2baz"]
+    p3["foo2baz_b2z"]
+    p4["foo2baz_f2b"]
+  end
+  p5["params"]
+  p1 -->|"out → in"| p4
+  p3 -->|"out → in"| p0
+  p4 -->|"out → in"| p3
+  p5 -.->|"out → note"| p0
+`
+	actual := wf.MermaidGraph()
+	if actual != expected {
+		t.Errorf("Mermaid graph is not as expected!\nEXPECTED:\n%s\nACTUAL:\n%s\n", expected, actual)
+	}
+
+	wf.PlotConf.EdgeLabels = false
+	if !strings.Contains(wf.MermaidGraph(), "  p5 -.-> p0\n") {
+		t.Errorf("Mermaid graph without edge labels is not as expected:\n%s", wf.MermaidGraph())
+	}
+}
+
+func TestSVGGraph(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("testwf", 4)
+
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	params := NewParamSource(wf, "params", "a", "b")
+	sw := newFooToBazSubWorkflow(wf, "foo2baz")
+	sw.In("in").From(foo.Out("out"))
+	cat := wf.NewProc("cat", "cat {i:in} > {o:out} # {p:note}")
+	cat.In("in").From(sw.Out("out"))
+	cat.InParam("note").From(params.Out())
+
+	svg := wf.SVGGraph()
+
+	// Processes should be laid out from left to right, in the order of the
+	// connections
+	xPos := map[string]int{}
+	for _, name := range []string{"foo", "foo2baz_f2b", "foo2baz_b2z", "cat"} {
+		m := regexp.MustCompile(`<text x="(\d+)"[^>]*>` + name + `</text>`).FindStringSubmatch(svg)
+		if m == nil {
+			t.Fatalf("Process %s not found in SVG graph:\n%s", name, svg)
+		}
+		xPos[name], _ = strconv.Atoi(m[1])
+	}
+	if !(xPos["foo"] < xPos["foo2baz_f2b"] && xPos["foo2baz_f2b"] < xPos["foo2baz_b2z"] && xPos["foo2baz_b2z"] < xPos["cat"]) {
+		t.Errorf("Processes not laid out from left to right in SVG graph: %v", xPos)
+	}
+
+	for _, expected := range []string{
+		`>foo2baz</text>`,
+		`stroke-dasharray="5,3"`,
+		`text-anchor="end">note</text>`,
+	} {
+		if !strings.Contains(svg, expected) {
+			t.Errorf("Expected SVG graph to contain %s, but it did not:\n%s", expected, svg)
+		}
+	}
+	if strings.Count(svg, `marker-end="url(#arrow)"`) != 4 {
+		t.Errorf("Expected 4 edges in SVG graph, but found %d:\n%s", strings.Count(svg, `marker-end="url(#arrow)"`), svg)
+	}
+}
+
+func TestPlotGraphSVG(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("testwf", 4)
+	p1 := wf.NewProc("p1", "echo p1 > {o:out}")
+	p2 := wf.NewProc("p2", "cat {i:in} > {o:out}")
+	p2.In("in").From(p1.Out("out"))
+
+	svgPath := ".tmp/graph/testwf.svg"
+	wf.PlotGraphSVG(svgPath)
+	if _, err := os.Stat(svgPath); os.IsNotExist(err) {
+		t.Errorf("PlotGraphSVG did not create SVG file: %s", svgPath)
+	}
+	cleanFiles(svgPath)
+}
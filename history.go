@@ -0,0 +1,158 @@
+package scipipe
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Run history
+// ----------------------------------------------------------------------------
+
+// HistoryFile is the path of the file that a record of each run of a workflow
+// is appended to, as one JSON object per line (See RunRecord). It can be set
+// to an empty string to not record any history.
+var HistoryFile = "log/scipipe-history.jsonl"
+
+const (
+	// RunStatusFinished is the status of a run where all tasks finished
+	RunStatusFinished = "finished"
+	// RunStatusFailed is the status of a run that was stopped by a failing
+	// task
+	RunStatusFailed = "failed"
+)
+
+// RunRecord is the record of a run of a workflow, in the run history
+type RunRecord struct {
+	RunID        string
+	Workflow     string
+	StartTime    time.Time
+	EndTime      time.Time
+	Status       string
+	TasksRun     int
+	TasksSkipped int
+	TasksFailed  int
+	LogFile      string
+	// Outputs are the paths of the files created by the tasks run, sorted
+	Outputs []string
+}
+
+// RunID returns the ID of the current (or last) run of the workflow, which is
+// set when the workflow starts running, and is used in the run history
+func (wf *Workflow) RunID() string {
+	wf.tasksLock.Lock()
+	defer wf.tasksLock.Unlock()
+	return wf.runID
+}
+
+// newRunID returns a new run ID, based on the current time, and a random
+// suffix
+func newRunID() string {
+	return time.Now().Format("20060102-150405") + "-" + randSeqLC(6)
+}
+
+// runRecord returns a record of the run of the workflow so far, with the
+// status status
+func (wf *Workflow) runRecord(status string) *RunRecord {
+	rec := &RunRecord{
+		Workflow: wf.Name(),
+		EndTime:  time.Now(),
+		Status:   status,
+		LogFile:  wf.logFile,
+		Outputs:  []string{},
+	}
+	for _, p := range progressProcs(wf.ProcsSorted()) {
+		s := p.Stats()
+		rec.TasksRun += s.TasksRun
+		rec.TasksSkipped += s.TasksSkipped
+		rec.TasksFailed += s.TasksFailed
+	}
+
+	wf.tasksLock.Lock()
+	rec.RunID = wf.runID
+	rec.StartTime = wf.startTime
+	rec.Outputs = append(rec.Outputs, wf.outputPaths...)
+	wf.tasksLock.Unlock()
+
+	sort.Strings(rec.Outputs)
+	return rec
+}
+
+// appendHistory appends a record of the run of the workflow, with the status
+// status, to HistoryFile. The record is only written once per run, even if
+// several tasks fail at the same time, and not at all if the run failed
+// before it started (such as when the workflow is not valid).
+func (wf *Workflow) appendHistory(status string) {
+	if HistoryFile == "" {
+		return
+	}
+	wf.tasksLock.Lock()
+	if wf.historyWritten || wf.runID == "" {
+		wf.tasksLock.Unlock()
+		return
+	}
+	wf.historyWritten = true
+	wf.tasksLock.Unlock()
+
+	err := AppendRunRecord(HistoryFile, wf.runRecord(status))
+	if err != nil {
+		Error.Printf("[Workflow:%s] Could not write run history to %s: %v\n", wf.Name(), HistoryFile, err)
+	}
+}
+
+// AppendRunRecord appends the run record rec, as one line of JSON, to the
+// history file at path, which is created if it does not exist
+func AppendRunRecord(path string, rec *RunRecord) error {
+	recJSON, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(recJSON, '\n'))
+	return err
+}
+
+// ReadHistory reads the run records from the history file at path, in the
+// order they were written (oldest first)
+func ReadHistory(path string) ([]*RunRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	recs := []*RunRecord{}
+	scanner := bufio.NewScanner(f)
+	// Records can be long, since they contain the paths of all outputs
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &RunRecord{}
+		err := json.Unmarshal(scanner.Bytes(), rec)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse line %d of history file %s: %v", lineNo, path, err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
@@ -0,0 +1,82 @@
+package scipipe
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestRunHistory(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	origHistoryFile := HistoryFile
+	HistoryFile = filepath.Join(dir, "history.jsonl")
+	defer func() { HistoryFile = origHistoryFile }()
+
+	runWf := func() *Workflow {
+		wf, _, _ := newFooBarTestWorkflow("TestRunHistory_WF", dir, "a", "b")
+		wf.Run()
+		return wf
+	}
+	wf1 := runWf()
+	wf2 := runWf() // All tasks skipped
+
+	recs, err := ReadHistory(HistoryFile)
+	assertNil(t, err)
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 run records, but got %d", len(recs))
+	}
+
+	assertEqualValues(t, wf1.RunID(), recs[0].RunID)
+	assertEqualValues(t, "TestRunHistory_WF", recs[0].Workflow)
+	assertEqualValues(t, RunStatusFinished, recs[0].Status)
+	assertEqualValues(t, 4, recs[0].TasksRun)
+	assertEqualValues(t, 0, recs[0].TasksSkipped)
+	assertEqualValues(t, wf1.logFile, recs[0].LogFile)
+	outputs := append([]string{}, recs[0].Outputs...)
+	sort.Strings(outputs)
+	assertEqualValues(t, []string{dir + "/a.txt", dir + "/a.txt.bar.txt", dir + "/b.txt", dir + "/b.txt.bar.txt"}, outputs)
+	if !recs[0].EndTime.After(recs[0].StartTime) {
+		t.Errorf("End time (%s) not after start time (%s)", recs[0].EndTime, recs[0].StartTime)
+	}
+
+	assertEqualValues(t, wf2.RunID(), recs[1].RunID)
+	assertEqualValues(t, 0, recs[1].TasksRun)
+	assertEqualValues(t, 4, recs[1].TasksSkipped)
+	assertEqualValues(t, []string{}, recs[1].Outputs)
+	if recs[0].RunID == recs[1].RunID {
+		t.Errorf("Run IDs of different runs are equal: %s", recs[0].RunID)
+	}
+}
+
+func TestRunHistoryOnFailure(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	origHistoryFile := HistoryFile
+	HistoryFile = filepath.Join(dir, "history.jsonl")
+	defer func() { HistoryFile = origHistoryFile }()
+
+	wf, _, _ := newFooBarTestWorkflow("TestRunHistoryOnFailure_WF", dir, "a")
+	// recordFailedRuns is what Fail calls before exiting, wherever the
+	// failure happens, so call it while the workflow is running
+	wf.OnTaskFinished(func(t *Task, auditInfo *AuditInfo, err error) {
+		recordFailedRuns()
+	})
+	wf.Run()
+
+	recs, err := ReadHistory(HistoryFile)
+	assertNil(t, err)
+	if len(recs) != 1 {
+		t.Fatalf("Expected 1 run record, but got %d", len(recs))
+	}
+	assertEqualValues(t, wf.RunID(), recs[0].RunID)
+	assertEqualValues(t, RunStatusFailed, recs[0].Status)
+
+	runningWorkflowsLock.Lock()
+	if runningWorkflows[wf] {
+		t.Error("Workflow still registered as running after it finished")
+	}
+	runningWorkflowsLock.Unlock()
+}
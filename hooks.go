@@ -0,0 +1,182 @@
+package scipipe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ----------------------------------------------------------------------------
+// Event hooks
+// ----------------------------------------------------------------------------
+
+// TaskHook is a function called on an event in the life cycle of a task
+type TaskHook func(t *Task)
+
+// TaskFinishedHook is a function called when a task has finished. auditInfo
+// is the audit info written for the outputs of the task, and err is non-nil if
+// the task failed, in which case auditInfo can be nil, if the task failed
+// before its audit info was created.
+type TaskFinishedHook func(t *Task, auditInfo *AuditInfo, err error)
+
+// ProcessHook is a function called on an event in the life cycle of a process
+type ProcessHook func(p WorkflowProcess)
+
+// WorkflowHook is a function called on an event in the life cycle of a
+// workflow
+type WorkflowHook func(wf *Workflow)
+
+// workflowHooks contains the hooks registered on a workflow
+type workflowHooks struct {
+	lock          sync.Mutex
+	taskScheduled []TaskHook
+	taskStarted   []TaskHook
+	taskFinished  []TaskFinishedHook
+	taskSkipped   []TaskHook
+	processDone   []ProcessHook
+	workflowDone  []WorkflowHook
+}
+
+// OnTaskScheduled registers a function to be called when a task has been
+// created from the inputs of a process, and is scheduled for execution. Note
+// that the task might still be skipped (See OnTaskSkipped), or have to wait
+// for a free slot for concurrent tasks before starting.
+//
+// Hooks are called synchronously from the go-routines running the processes
+// and tasks, so they should return quickly, and must be safe to call
+// concurrently. This applies to all the On... methods.
+func (wf *Workflow) OnTaskScheduled(hook TaskHook) {
+	wf.hooks.lock.Lock()
+	defer wf.hooks.lock.Unlock()
+	wf.hooks.taskScheduled = append(wf.hooks.taskScheduled, hook)
+}
+
+// OnTaskStarted registers a function to be called when a task starts
+// executing its command (or custom Go function)
+func (wf *Workflow) OnTaskStarted(hook TaskHook) {
+	wf.hooks.lock.Lock()
+	defer wf.hooks.lock.Unlock()
+	wf.hooks.taskStarted = append(wf.hooks.taskStarted, hook)
+}
+
+// OnTaskFinished registers a function to be called when a task has finished,
+// successfully or not. Since a failed task makes the workflow exit, hooks
+// called for failed tasks are the last thing to run before exiting.
+func (wf *Workflow) OnTaskFinished(hook TaskFinishedHook) {
+	wf.hooks.lock.Lock()
+	defer wf.hooks.lock.Unlock()
+	wf.hooks.taskFinished = append(wf.hooks.taskFinished, hook)
+}
+
+// OnTaskSkipped registers a function to be called when a task is skipped,
+// since its outputs already exist
+func (wf *Workflow) OnTaskSkipped(hook TaskHook) {
+	wf.hooks.lock.Lock()
+	defer wf.hooks.lock.Unlock()
+	wf.hooks.taskSkipped = append(wf.hooks.taskSkipped, hook)
+}
+
+// OnProcessDone registers a function to be called when a process has
+// finished running, which means that all its tasks are done, and its
+// out-ports are closed. It is called for the processes in sub-workflows, as
+// well as for the sub-workflows themselves.
+func (wf *Workflow) OnProcessDone(hook ProcessHook) {
+	wf.hooks.lock.Lock()
+	defer wf.hooks.lock.Unlock()
+	wf.hooks.processDone = append(wf.hooks.processDone, hook)
+}
+
+// OnWorkflowDone registers a function to be called when all the processes of
+// the workflow have finished running
+func (wf *Workflow) OnWorkflowDone(hook WorkflowHook) {
+	wf.hooks.lock.Lock()
+	defer wf.hooks.lock.Unlock()
+	wf.hooks.workflowDone = append(wf.hooks.workflowDone, hook)
+}
+
+// ----------------------------------------------------------------------------
+// Helper methods for calling the hooks
+// ----------------------------------------------------------------------------
+
+// taskScheduled is called by Process.Run, when the task t has been created
+func (wf *Workflow) taskScheduled(t *Task) {
+	wf.hooks.lock.Lock()
+	hooks := wf.hooks.taskScheduled
+	wf.hooks.lock.Unlock()
+	for _, hook := range hooks {
+		hook(t)
+	}
+}
+
+// taskStarted is called by Task.Execute, when the task t starts executing
+func (wf *Workflow) taskStarted(t *Task) {
+	wf.recordTaskStarted(t)
+	wf.hooks.lock.Lock()
+	hooks := wf.hooks.taskStarted
+	wf.hooks.lock.Unlock()
+	for _, hook := range hooks {
+		hook(t)
+	}
+}
+
+// taskSkipped is called by Task.Execute, when the task t is skipped
+func (wf *Workflow) taskSkipped(t *Task) {
+	wf.recordTaskFinished(t)
+	wf.hooks.lock.Lock()
+	hooks := wf.hooks.taskSkipped
+	wf.hooks.lock.Unlock()
+	for _, hook := range hooks {
+		hook(t)
+	}
+}
+
+// taskFinished is called by Task.Execute, when the task t has finished
+// successfully
+func (wf *Workflow) taskFinished(t *Task) {
+	wf.recordTaskFinished(t)
+	wf.recordTaskAuditInfo(t)
+	wf.runTaskFinishedHooks(t, nil)
+}
+
+// taskFailed is called by Task.Fail, when the task t has failed with the
+// error message msg
+func (wf *Workflow) taskFailed(t *Task, msg interface{}) {
+	wf.recordTaskFailed(t, msg)
+	err, ok := msg.(error)
+	if !ok {
+		err = errors.New(strings.TrimSpace(fmt.Sprintf("%v", msg)))
+	}
+	wf.runTaskFinishedHooks(t, err)
+}
+
+func (wf *Workflow) runTaskFinishedHooks(t *Task, err error) {
+	wf.hooks.lock.Lock()
+	hooks := wf.hooks.taskFinished
+	wf.hooks.lock.Unlock()
+	for _, hook := range hooks {
+		hook(t, t.auditInfo, err)
+	}
+}
+
+// runProc runs the process proc, and calls the OnProcessDone hooks when it
+// has finished
+func (wf *Workflow) runProc(proc WorkflowProcess) {
+	proc.Run()
+	wf.hooks.lock.Lock()
+	hooks := wf.hooks.processDone
+	wf.hooks.lock.Unlock()
+	for _, hook := range hooks {
+		hook(proc)
+	}
+}
+
+// workflowDone is called by Workflow.Run when all processes have finished
+func (wf *Workflow) workflowDone() {
+	wf.hooks.lock.Lock()
+	hooks := wf.hooks.workflowDone
+	wf.hooks.lock.Unlock()
+	for _, hook := range hooks {
+		hook(wf)
+	}
+}
@@ -0,0 +1,70 @@
+package scipipe
+
+import (
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	wf, _, _ := newFooBarTestWorkflow("TestHooks_WF", dir, "a", "b")
+
+	lock := sync.Mutex{}
+	events := map[string]int{}
+	procsDone := []string{}
+	count := func(event string) {
+		lock.Lock()
+		events[event]++
+		lock.Unlock()
+	}
+	wf.OnTaskScheduled(func(t *Task) { count("scheduled") })
+	wf.OnTaskStarted(func(t *Task) { count("started") })
+	wf.OnTaskSkipped(func(t *Task) { count("skipped") })
+	wf.OnTaskFinished(func(tk *Task, auditInfo *AuditInfo, err error) {
+		count("finished")
+		if err != nil {
+			t.Errorf("Unexpected error for finished task: %v", err)
+		}
+		if auditInfo == nil || auditInfo.ProcessName != tk.Process.Name() {
+			t.Errorf("Audit info for finished task not set correctly: %v", auditInfo)
+		}
+	})
+	wf.OnProcessDone(func(p WorkflowProcess) {
+		lock.Lock()
+		procsDone = append(procsDone, p.Name())
+		lock.Unlock()
+	})
+	wf.OnWorkflowDone(func(doneWf *Workflow) {
+		lock.Lock()
+		defer lock.Unlock()
+		if doneWf != wf {
+			t.Errorf("Wrong workflow passed to OnWorkflowDone hook")
+		}
+		events["workflowDone"]++
+		// All processes should be done before the workflow is
+		sort.Strings(procsDone)
+		assertEqualValues(t, []string{"bar", "foo"}, procsDone)
+	})
+
+	wf.Run()
+
+	assertEqualValues(t, map[string]int{
+		"scheduled":    4,
+		"started":      4,
+		"finished":     4,
+		"workflowDone": 1,
+	}, events)
+
+	// Re-running skips all tasks, since the outputs exist
+	events = map[string]int{}
+	wf2, _, _ := newFooBarTestWorkflow("TestHooks_WF", dir, "a", "b")
+	wf2.OnTaskScheduled(func(t *Task) { count("scheduled") })
+	wf2.OnTaskStarted(func(t *Task) { count("started") })
+	wf2.OnTaskSkipped(func(t *Task) { count("skipped") })
+	wf2.Run()
+
+	assertEqualValues(t, map[string]int{"scheduled": 4, "skipped": 4}, events)
+}
@@ -0,0 +1,106 @@
+package scipipe
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Locking the output directory against concurrent runs
+// ----------------------------------------------------------------------------
+
+// LockFileName is the name of the lock file that a workflow takes an advisory
+// lock on, in the directory where it writes its outputs (the current working
+// directory), while running. This prevents two runs in the same directory from
+// corrupting each other's temporary folders.
+const LockFileName = ".scipipe.lock"
+
+// errAlreadyLocked is returned by the platform specific lockFile function,
+// when another process is holding the lock
+var errAlreadyLocked = errors.New("lock is held by another process")
+
+// lock takes an exclusive, advisory lock on the lock file in the current
+// working directory, and writes the PID and host name of the current process
+// into it, so that a concurrent run can report who is holding the lock. The
+// lock is released automatically by the operating system if the process exits
+// or crashes.
+func (wf *Workflow) lock() error {
+	f, holder, err := lockDir(".", "running workflow "+wf.Name())
+	if err == errAlreadyLocked {
+		return fmt.Errorf("Another workflow is already running in this directory (lock file %s is held by: %s). "+
+			"Wait for it to finish, or run the workflow in a different directory.", LockFileName, holder)
+	} else if err != nil {
+		return err
+	}
+	wf.lockFile = f
+	return nil
+}
+
+// unlock clears and releases the lock taken with lock
+func (wf *Workflow) unlock() {
+	if wf.lockFile == nil {
+		return
+	}
+	err := unlockDir(wf.lockFile)
+	if err != nil {
+		Warning.Printf("[Workflow:%s] Could not release lock on %s: %v\n", wf.Name(), LockFileName, err)
+	}
+	wf.lockFile = nil
+}
+
+// lockDir takes an exclusive, advisory lock on the lock file in dir, and
+// writes the PID and host name of the current process, followed by activity,
+// into it. If the lock is held by another process (or another open lock file
+// in this process), errAlreadyLocked is returned, together with the
+// description of the holder found in the lock file. On Windows, locking is
+// not supported, so lockDir always succeeds there, after warning about it
+// once.
+func lockDir(dir string, activity string) (*os.File, string, error) {
+	lockPath := filepath.Join(dir, LockFileName)
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, "", fmt.Errorf("Could not open lock file %s: %v", lockPath, err)
+	}
+	err = lockFile(f)
+	if err == errAlreadyLocked {
+		holder, _ := ioutil.ReadAll(f)
+		f.Close()
+		return nil, lockHolderString(string(holder)), errAlreadyLocked
+	} else if err != nil {
+		f.Close()
+		return nil, "", fmt.Errorf("Could not lock lock file %s: %v", lockPath, err)
+	}
+
+	hostName, err := os.Hostname()
+	if err != nil {
+		hostName = "unknown"
+	}
+	holder := fmt.Sprintf("PID %d on host %s, %s since %s", os.Getpid(), hostName, activity, time.Now().Format("2006-01-02 15:04:05"))
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(holder+"\n"), 0)
+	}
+	return f, holder, nil
+}
+
+// unlockDir clears and releases a lock taken with lockDir, and closes the
+// lock file. The lock file itself is left in place, since removing it could
+// let two processes hold locks on different files with the same name.
+func unlockDir(f *os.File) error {
+	f.Truncate(0)
+	err := unlockFile(f)
+	f.Close()
+	return err
+}
+
+func lockHolderString(holder string) string {
+	holder = strings.TrimSpace(holder)
+	if holder == "" {
+		return "unknown process"
+	}
+	return holder
+}
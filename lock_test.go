@@ -0,0 +1,39 @@
+package scipipe
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWorkflowLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Locking is not supported on Windows")
+	}
+	initTestLogs()
+	defer cleanFiles(LockFileName)
+
+	wf1 := newWorkflowWithoutLogging("wf1", 1)
+	wf2 := newWorkflowWithoutLogging("wf2", 1)
+
+	err := wf1.lock()
+	assertNil(t, err, "Could not take lock")
+
+	err = wf2.lock()
+	if err == nil {
+		t.Fatal("Expected error when taking lock held by another workflow, but got none")
+	}
+	hostName, _ := os.Hostname()
+	for _, expected := range []string{fmt.Sprintf("PID %d", os.Getpid()), "host " + hostName, "workflow wf1"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected lock error to contain '%s', but got: %s", expected, err.Error())
+		}
+	}
+
+	wf1.unlock()
+	err = wf2.lock()
+	assertNil(t, err, "Could not take lock after it was released")
+	wf2.unlock()
+}
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package scipipe
+
+import (
+	"os"
+	"sync"
+)
+
+// flock is not available on Windows, so no locking is done there, which is
+// warned about (once) the first time a lock is taken
+
+var lockUnsupportedWarning sync.Once
+
+func lockFile(f *os.File) error {
+	lockUnsupportedWarning.Do(func() {
+		Warning.Printf("Locking of the output directory is not supported on Windows, so nothing prevents another workflow from running in the same directory at the same time (Lock file: %s)\n", f.Name())
+	})
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -63,6 +63,7 @@ func InitLog(
 			log.Ldate|log.Ltime)
 
 		logExists = true
+		initLogFormat()
 	}
 }
 
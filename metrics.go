@@ -0,0 +1,183 @@
+package scipipe
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Prometheus metrics
+// ----------------------------------------------------------------------------
+
+// taskDurationBuckets are the upper bounds, in seconds, of the buckets of the
+// task duration histogram. Since tasks in scientific workflows often run for
+// minutes or hours, these are much larger than the Prometheus defaults.
+var taskDurationBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 14400, 28800, 86400}
+
+// processMetrics contains the metrics collected for the tasks of a process
+type processMetrics struct {
+	started      int
+	finished     int
+	failed       int
+	skipped      int
+	bytesWritten int64
+	// durationCounts contains the number of finished tasks in each bucket of
+	// taskDurationBuckets (not cumulative, unlike in the exposition format)
+	durationCounts []int
+	durationSum    float64
+}
+
+func newProcessMetrics() *processMetrics {
+	return &processMetrics{durationCounts: make([]int, len(taskDurationBuckets))}
+}
+
+// observeDuration adds a task duration (in seconds) to the duration histogram
+func (m *processMetrics) observeDuration(seconds float64) {
+	m.durationSum += seconds
+	for i, bound := range taskDurationBuckets {
+		if seconds <= bound {
+			m.durationCounts[i]++
+			return
+		}
+	}
+	// Durations above the largest bound are only included in the +Inf
+	// bucket, which is the total count
+}
+
+// procMetrics returns the metrics for the process with name procName, which
+// are created if needed. The tasksLock of the workflow must be held when
+// calling it.
+func (wf *Workflow) procMetrics(procName string) *processMetrics {
+	if wf.metrics == nil {
+		wf.metrics = map[string]*processMetrics{}
+	}
+	m, ok := wf.metrics[procName]
+	if !ok {
+		m = newProcessMetrics()
+		wf.metrics[procName] = m
+	}
+	return m
+}
+
+// outputBytes returns the total size of the (finalized) outputs of t. Outputs
+// that can't be found, such as FIFO files that are already removed, are not
+// counted.
+func outputBytes(t *Task) int64 {
+	var size int64
+	for _, oip := range t.OutIPs {
+		fi, err := os.Stat(oip.Path())
+		if err != nil {
+			continue
+		}
+		size += fi.Size()
+	}
+	return size
+}
+
+// MetricsHandler returns an http.Handler serving the metrics of the workflow
+// in the Prometheus text exposition format. It is also served on /metrics by
+// the status API (See ServeStatus).
+func (wf *Workflow) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		fmt.Fprint(w, wf.Metrics())
+	})
+}
+
+// Metrics returns the metrics of the workflow in the Prometheus text
+// exposition format. The following metrics are included:
+//
+//	scipipe_tasks_started_total        Tasks started, per process
+//	scipipe_tasks_finished_total       Tasks finished, per process
+//	scipipe_tasks_failed_total         Tasks failed, per process
+//	scipipe_tasks_skipped_total        Tasks skipped since outputs existed, per process
+//	scipipe_task_duration_seconds      Histogram of task durations, per process
+//	scipipe_output_bytes_total         Bytes written to outputs of finished tasks, per process
+//	scipipe_slots_used                 Slots for concurrent tasks currently used
+//	scipipe_slots_total                Slots for concurrent tasks in total
+//	scipipe_port_buffer_size           Buffer size of in-ports, per process and port
+//	scipipe_port_buffer_fill           IPs or params waiting in in-port buffers, per process and port
+func (wf *Workflow) Metrics() string {
+	wfLabel := fmt.Sprintf(`workflow="%s"`, escapeLabelValue(wf.Name()))
+	buf := &bytes.Buffer{}
+
+	wf.tasksLock.Lock()
+	procNames := []string{}
+	metrics := map[string]processMetrics{}
+	for name, m := range wf.metrics {
+		procNames = append(procNames, name)
+		mCopy := *m
+		mCopy.durationCounts = append([]int{}, m.durationCounts...)
+		metrics[name] = mCopy
+	}
+	wf.tasksLock.Unlock()
+	sort.Strings(procNames)
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(m processMetrics) string
+	}{
+		{"scipipe_tasks_started_total", "Number of tasks started.", func(m processMetrics) string { return fmt.Sprint(m.started) }},
+		{"scipipe_tasks_finished_total", "Number of tasks finished successfully.", func(m processMetrics) string { return fmt.Sprint(m.finished) }},
+		{"scipipe_tasks_failed_total", "Number of tasks failed.", func(m processMetrics) string { return fmt.Sprint(m.failed) }},
+		{"scipipe_tasks_skipped_total", "Number of tasks skipped since their outputs already existed.", func(m processMetrics) string { return fmt.Sprint(m.skipped) }},
+		{"scipipe_output_bytes_total", "Number of bytes written to the outputs of finished tasks.", func(m processMetrics) string { return fmt.Sprint(m.bytesWritten) }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, procName := range procNames {
+			fmt.Fprintf(buf, "%s{%s,process=\"%s\"} %s\n", c.name, wfLabel, escapeLabelValue(procName), c.value(metrics[procName]))
+		}
+	}
+
+	fmt.Fprint(buf, "# HELP scipipe_task_duration_seconds Duration of finished tasks.\n# TYPE scipipe_task_duration_seconds histogram\n")
+	for _, procName := range procNames {
+		m := metrics[procName]
+		labels := fmt.Sprintf(`%s,process="%s"`, wfLabel, escapeLabelValue(procName))
+		cumulative := 0
+		for i, bound := range taskDurationBuckets {
+			cumulative += m.durationCounts[i]
+			fmt.Fprintf(buf, "scipipe_task_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bound, cumulative)
+		}
+		fmt.Fprintf(buf, "scipipe_task_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, m.finished)
+		fmt.Fprintf(buf, "scipipe_task_duration_seconds_sum{%s} %g\n", labels, m.durationSum)
+		fmt.Fprintf(buf, "scipipe_task_duration_seconds_count{%s} %d\n", labels, m.finished)
+	}
+
+	fmt.Fprintf(buf, "# HELP scipipe_slots_used Number of slots for concurrent tasks currently used.\n# TYPE scipipe_slots_used gauge\n")
+	fmt.Fprintf(buf, "scipipe_slots_used{%s} %d\n", wfLabel, len(wf.concurrentTasks))
+	fmt.Fprintf(buf, "# HELP scipipe_slots_total Number of slots for concurrent tasks in total.\n# TYPE scipipe_slots_total gauge\n")
+	fmt.Fprintf(buf, "scipipe_slots_total{%s} %d\n", wfLabel, cap(wf.concurrentTasks))
+
+	sizes := &bytes.Buffer{}
+	fills := &bytes.Buffer{}
+	for _, p := range wf.statusProcs(wf.ProcsSorted()) {
+		inPorts := p.InPorts()
+		for _, name := range sortedInPortMapKeys(inPorts) {
+			labels := fmt.Sprintf(`%s,process="%s",port="%s"`, wfLabel, escapeLabelValue(p.Name()), escapeLabelValue(name))
+			fmt.Fprintf(sizes, "scipipe_port_buffer_size{%s} %d\n", labels, cap(inPorts[name].Chan))
+			fmt.Fprintf(fills, "scipipe_port_buffer_fill{%s} %d\n", labels, len(inPorts[name].Chan))
+		}
+		inParamPorts := p.InParamPorts()
+		for _, name := range sortedInParamPortMapKeys(inParamPorts) {
+			labels := fmt.Sprintf(`%s,process="%s",port="%s"`, wfLabel, escapeLabelValue(p.Name()), escapeLabelValue(name))
+			fmt.Fprintf(sizes, "scipipe_port_buffer_size{%s} %d\n", labels, cap(inParamPorts[name].Chan))
+			fmt.Fprintf(fills, "scipipe_port_buffer_fill{%s} %d\n", labels, len(inParamPorts[name].Chan))
+		}
+	}
+	fmt.Fprintf(buf, "# HELP scipipe_port_buffer_size Buffer size of in-ports.\n# TYPE scipipe_port_buffer_size gauge\n%s", sizes)
+	fmt.Fprintf(buf, "# HELP scipipe_port_buffer_fill Number of IPs or params waiting in the buffers of in-ports.\n# TYPE scipipe_port_buffer_fill gauge\n%s", fills)
+
+	return buf.String()
+}
+
+// escapeLabelValue escapes backslashes, double quotes and newlines in a label
+// value, as required by the Prometheus text exposition format
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,77 @@
+package scipipe
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	wf, _, _ := newFooBarTestWorkflow("TestMetrics_WF", dir, "a", "b", "c")
+	wf.Run()
+
+	server := httptest.NewServer(wf.StatusHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Could not get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Could not read metrics: %v", err)
+	}
+	metrics := string(body)
+
+	for _, expected := range []string{
+		"# TYPE scipipe_tasks_started_total counter",
+		`scipipe_tasks_started_total{workflow="TestMetrics_WF",process="foo"} 3`,
+		`scipipe_tasks_started_total{workflow="TestMetrics_WF",process="bar"} 3`,
+		`scipipe_tasks_finished_total{workflow="TestMetrics_WF",process="foo"} 3`,
+		`scipipe_tasks_failed_total{workflow="TestMetrics_WF",process="foo"} 0`,
+		`scipipe_tasks_skipped_total{workflow="TestMetrics_WF",process="foo"} 0`,
+		// "a\n", "b\n" and "c\n"
+		`scipipe_output_bytes_total{workflow="TestMetrics_WF",process="foo"} 6`,
+		"# TYPE scipipe_task_duration_seconds histogram",
+		`scipipe_task_duration_seconds_bucket{workflow="TestMetrics_WF",process="foo",le="+Inf"} 3`,
+		`scipipe_task_duration_seconds_count{workflow="TestMetrics_WF",process="foo"} 3`,
+		`scipipe_slots_used{workflow="TestMetrics_WF"} 0`,
+		`scipipe_slots_total{workflow="TestMetrics_WF"} 4`,
+		`scipipe_port_buffer_fill{workflow="TestMetrics_WF",process="foo",port="word"} 0`,
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("Metrics do not contain %q:\n%s", expected, metrics)
+		}
+	}
+
+	// Re-running should skip all the tasks, since the outputs exist
+	wf2, _, _ := newFooBarTestWorkflow("TestMetrics_WF", dir, "a", "b", "c")
+	wf2.Run()
+	if !strings.Contains(wf2.Metrics(), `scipipe_tasks_skipped_total{workflow="TestMetrics_WF",process="foo"} 3`) {
+		t.Errorf("Metrics do not contain skipped tasks:\n%s", wf2.Metrics())
+	}
+}
+
+func TestObserveDuration(t *testing.T) {
+	m := newProcessMetrics()
+	for _, seconds := range []float64{0.5, 1, 3, 100000} {
+		m.observeDuration(seconds)
+	}
+	// 0.5 and 1 are in the first bucket (le=1), 3 in the second (le=5), and
+	// 100000 is above the largest bound, so only in the +Inf bucket
+	expected := make([]int, len(taskDurationBuckets))
+	expected[0] = 2
+	expected[1] = 1
+	assertEqualValues(t, expected, m.durationCounts)
+	assertEqualValues(t, 100004.5, m.durationSum)
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	assertEqualValues(t, `a\"b\\c\nd`, escapeLabelValue("a\"b\\c\nd"))
+}
@@ -52,6 +52,19 @@ func cleanFilePatterns(filePatterns ...string) {
 	}
 }
 
+// newFooBarTestWorkflow returns a workflow where the process foo writes each of
+// the words to a file in dir, and the process bar copies each of those files
+func newFooBarTestWorkflow(name string, dir string, words ...string) (wf *Workflow, foo *Process, bar *Process) {
+	wf = NewWorkflow(name, 4)
+	foo = wf.NewProc("foo", "echo {p:word} > {o:out}")
+	foo.InParam("word").FromStr(words...)
+	foo.SetOut("out", dir+"/{p:word}.txt")
+	bar = wf.NewProc("bar", "cat {i:in} > {o:out}")
+	bar.In("in").From(foo.Out("out"))
+	bar.SetOut("out", "{i:in}.bar.txt")
+	return wf, foo, bar
+}
+
 func assertIsType(t *testing.T, expected interface{}, actual interface{}) {
 	if !reflect.DeepEqual(reflect.TypeOf(expected), reflect.TypeOf(actual)) {
 		t.Errorf("Types do not match! (%s) and (%s)\n", reflect.TypeOf(expected).String(), reflect.TypeOf(actual).String())
@@ -14,12 +14,15 @@ import (
 // processes, from its own process, and with which it is communicating via
 // channels under the hood
 type InPort struct {
-	Chan        chan *FileIP
-	name        string
-	process     WorkflowProcess
-	RemotePorts map[string]*OutPort
-	ready       bool
-	closeLock   sync.Mutex
+	Chan                 chan *FileIP
+	name                 string
+	process              WorkflowProcess
+	RemotePorts          map[string]*OutPort
+	ready                bool
+	closeLock            sync.Mutex
+	duplicateRemotePorts []string
+	bufStats             BufferStats
+	bufStatsLock         sync.Mutex
 }
 
 // NewInPort returns a new InPort struct
@@ -51,10 +54,13 @@ func (pt *InPort) SetProcess(p WorkflowProcess) {
 	pt.process = p
 }
 
-// AddRemotePort adds a remote OutPort to the InPort
+// AddRemotePort adds a remote OutPort to the InPort. Adding a remote port with
+// the same name more than once is recorded, and reported when the workflow is
+// validated.
 func (pt *InPort) AddRemotePort(rpt *OutPort) {
 	if pt.RemotePorts[rpt.Name()] != nil {
-		pt.Failf("A remote port with name (%s) already exists", rpt.Name())
+		pt.duplicateRemotePorts = append(pt.duplicateRemotePorts, rpt.Name())
+		return
 	}
 	pt.RemotePorts[rpt.Name()] = rpt
 }
@@ -94,12 +100,39 @@ func (pt *InPort) Ready() bool {
 	return pt.ready
 }
 
+// SetBufferSize sets the size of the channel buffer of the in-port, which is
+// otherwise set by the SCIPIPE_BUFSIZE environment variable, or defaults to
+// BUFSIZE. Since each in-port has its own channel, shared by all out-ports
+// connected to it, this sets the buffer size for all connections to the
+// in-port. A large buffer lets upstream processes run ahead, while a small
+// one applies tight backpressure. Since the channel is replaced, it must be
+// called before the in-port is connected to any out-port.
+func (pt *InPort) SetBufferSize(size int) {
+	if len(pt.RemotePorts) > 0 {
+		pt.Failf("Can not change buffer size of in-port after it has been connected, since IPs might already be sent on it. Call SetBufferSize before connecting the in-port.")
+	}
+	pt.Chan = make(chan *FileIP, size)
+}
+
 // Send sends IPs to the in-port, and is supposed to be called from the remote
 // (out-) port, to send to this in-port
 func (pt *InPort) Send(ip *FileIP) {
+	pt.bufStatsLock.Lock()
+	pt.bufStats.record(len(pt.Chan), cap(pt.Chan))
+	pt.bufStatsLock.Unlock()
 	pt.Chan <- ip
 }
 
+// BufferStats returns statistics about the usage of the channel buffer of the
+// in-port, which can be used to find bottlenecks in the workflow
+func (pt *InPort) BufferStats() BufferStats {
+	pt.bufStatsLock.Lock()
+	defer pt.bufStatsLock.Unlock()
+	stats := pt.bufStats
+	stats.Size = cap(pt.Chan)
+	return stats
+}
+
 // Recv receives IPs from the port
 func (pt *InPort) Recv() *FileIP {
 	return <-pt.Chan
@@ -116,6 +149,14 @@ func (pt *InPort) CloseConnection(rptName string) {
 	pt.closeLock.Unlock()
 }
 
+// isConnectedTo returns true if the connection from the out-port rpt is still
+// open
+func (pt *InPort) isConnectedTo(rpt *OutPort) bool {
+	pt.closeLock.Lock()
+	defer pt.closeLock.Unlock()
+	return rpt != nil && pt.RemotePorts[rpt.Name()] == rpt
+}
+
 // Failf fails with a message that includes the process name
 func (pt *InPort) Failf(msg string, parts ...interface{}) {
 	pt.Fail(fmt.Sprintf(msg, parts...))
@@ -138,6 +179,8 @@ type OutPort struct {
 	process     WorkflowProcess
 	RemotePorts map[string]*InPort
 	ready       bool
+	sent        map[*InPort]int
+	sentLock    sync.Mutex
 }
 
 // NewOutPort returns a new OutPort struct
@@ -168,10 +211,12 @@ func (pt *OutPort) SetProcess(p WorkflowProcess) {
 	pt.process = p
 }
 
-// AddRemotePort adds a remote InPort to the OutPort
+// AddRemotePort adds a remote InPort to the OutPort. Duplicate connections are
+// recorded on the in-port side, so adding the same remote port again is a
+// no-op here.
 func (pt *OutPort) AddRemotePort(rpt *InPort) {
 	if _, ok := pt.RemotePorts[rpt.Name()]; ok {
-		pt.Failf("A remote port with name (%s) already exists", rpt.Name())
+		return
 	}
 	pt.RemotePorts[rpt.Name()] = rpt
 }
@@ -216,9 +261,23 @@ func (pt *OutPort) Send(ip *FileIP) {
 	for _, rpt := range pt.RemotePorts {
 		Debug.Printf("Sending on out-port (%s) connected to in-port (%s)", pt.Name(), rpt.Name())
 		rpt.Send(ip)
+		pt.sentLock.Lock()
+		if pt.sent == nil {
+			pt.sent = map[*InPort]int{}
+		}
+		pt.sent[rpt]++
+		pt.sentLock.Unlock()
 	}
 }
 
+// sentCount returns the number of IPs sent from the out-port to the in-port
+// rpt so far
+func (pt *OutPort) sentCount(rpt *InPort) int {
+	pt.sentLock.Lock()
+	defer pt.sentLock.Unlock()
+	return pt.sent[rpt]
+}
+
 // Close closes the connection between this port and all the ports it is
 // connected to. If this port is the last connected port to an in-port, that
 // in-ports channel will also be closed.
@@ -246,12 +305,15 @@ func (pt *OutPort) Fail(msg interface{}) {
 
 // InParamPort is an in-port for parameter values of string type
 type InParamPort struct {
-	Chan        chan string
-	name        string
-	process     WorkflowProcess
-	RemotePorts map[string]*OutParamPort
-	ready       bool
-	closeLock   sync.Mutex
+	Chan                 chan string
+	name                 string
+	process              WorkflowProcess
+	RemotePorts          map[string]*OutParamPort
+	ready                bool
+	closeLock            sync.Mutex
+	duplicateRemotePorts []string
+	bufStats             BufferStats
+	bufStatsLock         sync.Mutex
 }
 
 // NewInParamPort returns a new InParamPort
@@ -281,10 +343,13 @@ func (pip *InParamPort) SetProcess(p WorkflowProcess) {
 	pip.process = p
 }
 
-// AddRemotePort adds a remote OutParamPort to the InParamPort
+// AddRemotePort adds a remote OutParamPort to the InParamPort. Adding a remote
+// port with the same name more than once is recorded, and reported when the
+// workflow is validated.
 func (pip *InParamPort) AddRemotePort(pop *OutParamPort) {
 	if pip.RemotePorts[pop.Name()] != nil {
-		pip.Failf("A remote param port with name (%s) already exists", pop.Name())
+		pip.duplicateRemotePorts = append(pip.duplicateRemotePorts, pop.Name())
+		return
 	}
 	pip.RemotePorts[pop.Name()] = pop
 }
@@ -339,12 +404,36 @@ func (pip *InParamPort) Ready() bool {
 	return pip.ready
 }
 
+// SetBufferSize sets the size of the channel buffer of the param in-port (See
+// InPort.SetBufferSize). It must be called before the param in-port is
+// connected, which includes calling any of the FromStr, FromInt or FromFloat
+// methods, since these start sending parameters right away.
+func (pip *InParamPort) SetBufferSize(size int) {
+	if len(pip.RemotePorts) > 0 {
+		pip.Failf("Can not change buffer size of param in-port after it has been connected, since params might already be sent on it. Call SetBufferSize before connecting the param in-port.")
+	}
+	pip.Chan = make(chan string, size)
+}
+
 // Send sends IPs to the in-port, and is supposed to be called from the remote
 // (out-) port, to send to this in-port
 func (pip *InParamPort) Send(param string) {
+	pip.bufStatsLock.Lock()
+	pip.bufStats.record(len(pip.Chan), cap(pip.Chan))
+	pip.bufStatsLock.Unlock()
 	pip.Chan <- param
 }
 
+// BufferStats returns statistics about the usage of the channel buffer of the
+// param in-port (See InPort.BufferStats)
+func (pip *InParamPort) BufferStats() BufferStats {
+	pip.bufStatsLock.Lock()
+	defer pip.bufStatsLock.Unlock()
+	stats := pip.bufStats
+	stats.Size = cap(pip.Chan)
+	return stats
+}
+
 // Recv receiveds a param value over the ports connection
 func (pip *InParamPort) Recv() string {
 	return <-pip.Chan
@@ -361,6 +450,14 @@ func (pip *InParamPort) CloseConnection(popName string) {
 	pip.closeLock.Unlock()
 }
 
+// isConnectedTo returns true if the connection from the param out-port pop is
+// still open
+func (pip *InParamPort) isConnectedTo(pop *OutParamPort) bool {
+	pip.closeLock.Lock()
+	defer pip.closeLock.Unlock()
+	return pop != nil && pip.RemotePorts[pop.Name()] == pop
+}
+
 // Failf fails with a message that includes the process name
 func (pt *InParamPort) Failf(msg string, parts ...interface{}) {
 	pt.Fail(fmt.Sprintf(msg, parts...))
@@ -381,6 +478,8 @@ type OutParamPort struct {
 	process     WorkflowProcess
 	RemotePorts map[string]*InParamPort
 	ready       bool
+	sent        map[*InParamPort]int
+	sentLock    sync.Mutex
 }
 
 // NewOutParamPort returns a new OutParamPort
@@ -409,10 +508,12 @@ func (pop *OutParamPort) SetProcess(p WorkflowProcess) {
 	pop.process = p
 }
 
-// AddRemotePort adds a remote InParamPort to the OutParamPort
+// AddRemotePort adds a remote InParamPort to the OutParamPort. Duplicate
+// connections are recorded on the in-port side, so adding the same remote port
+// again is a no-op here.
 func (pop *OutParamPort) AddRemotePort(pip *InParamPort) {
 	if pop.RemotePorts[pip.Name()] != nil {
-		pop.Failf("A remote param port with name (%s) already exists", pip.Name())
+		return
 	}
 	pop.RemotePorts[pip.Name()] = pip
 }
@@ -454,9 +555,23 @@ func (pop *OutParamPort) Send(param string) {
 	for _, pip := range pop.RemotePorts {
 		Debug.Printf("Sending on out-param-port (%s) connected to in-param-port (%s)", pop.Name(), pip.Name())
 		pip.Send(param)
+		pop.sentLock.Lock()
+		if pop.sent == nil {
+			pop.sent = map[*InParamPort]int{}
+		}
+		pop.sent[pip]++
+		pop.sentLock.Unlock()
 	}
 }
 
+// sentCount returns the number of parameters sent from the param out-port to
+// the param in-port pip so far
+func (pop *OutParamPort) sentCount(pip *InParamPort) int {
+	pop.sentLock.Lock()
+	defer pop.sentLock.Unlock()
+	return pop.sent[pip]
+}
+
 // Close closes the connection between this port and all the ports it is
 // connected to. If this port is the last connected port to an in-port, that
 // in-ports channel will also be closed.
@@ -477,3 +592,46 @@ func (pt *OutParamPort) Failf(msg string, parts ...interface{}) {
 func (pt *OutParamPort) Fail(msg interface{}) {
 	Failf("[Out-Param-Port:%s] %s", pt.Name(), msg)
 }
+
+// ------------------------------------------------------------------------
+// Buffer statistics
+// ------------------------------------------------------------------------
+
+// BufferStats contains statistics about the usage of the channel buffer of an
+// in-port or param in-port, as observed each time something is sent to it
+type BufferStats struct {
+	// Size is the capacity of the buffer
+	Size int
+	// Sends is the number of IPs or params sent to the port
+	Sends int
+	// MaxOccupancy is the largest number of IPs or params found waiting in
+	// the buffer when sending
+	MaxOccupancy int
+	// FullSends is the number of sends that found the buffer full, and so had
+	// to wait for the receiving process. Many full sends indicate that the
+	// receiving process is a bottleneck.
+	FullSends    int
+	occupancySum int
+}
+
+// MeanOccupancy returns the mean number of IPs or params found waiting in the
+// buffer when sending
+func (s BufferStats) MeanOccupancy() float64 {
+	if s.Sends == 0 {
+		return 0
+	}
+	return float64(s.occupancySum) / float64(s.Sends)
+}
+
+// record records a send to a buffer with capacity size, that had occupancy
+// items in it
+func (s *BufferStats) record(occupancy int, size int) {
+	s.Sends++
+	s.occupancySum += occupancy
+	if occupancy > s.MaxOccupancy {
+		s.MaxOccupancy = occupancy
+	}
+	if occupancy >= size {
+		s.FullSends++
+	}
+}
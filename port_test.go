@@ -4,6 +4,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestMultiInPort(t *testing.T) {
@@ -64,6 +65,90 @@ func TestInPortSendRecv(t *testing.T) {
 	}
 }
 
+func TestInPortBufferSizeAndStats(t *testing.T) {
+	inp := NewInPort("test_inport")
+	inp.process = NewBogusProcess("bogus_process")
+	inp.SetBufferSize(2)
+
+	for _, path := range []string{".tmp/a.txt", ".tmp/b.txt"} {
+		ip, err := NewFileIP(path)
+		Check(err)
+		inp.Send(ip)
+	}
+	stats := inp.BufferStats()
+	assertEqualValues(t, 2, stats.Size, "Wrong buffer size")
+	assertEqualValues(t, 2, stats.Sends, "Wrong number of sends")
+	assertEqualValues(t, 1, stats.MaxOccupancy, "Wrong max occupancy")
+	assertEqualValues(t, 0, stats.FullSends, "Wrong number of full sends")
+	assertEqualValues(t, 0.5, stats.MeanOccupancy(), "Wrong mean occupancy")
+
+	// The buffer is now full, so the next send has to wait until we receive
+	ip, err := NewFileIP(".tmp/c.txt")
+	Check(err)
+	done := make(chan struct{})
+	go func() {
+		inp.Send(ip)
+		close(done)
+	}()
+	for inp.BufferStats().Sends < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	inp.Recv()
+	<-done
+	stats = inp.BufferStats()
+	assertEqualValues(t, 3, stats.Sends, "Wrong number of sends")
+	assertEqualValues(t, 2, stats.MaxOccupancy, "Wrong max occupancy")
+	assertEqualValues(t, 1, stats.FullSends, "Wrong number of full sends")
+}
+
+func TestWorkflowBufferStats(t *testing.T) {
+	initTestLogs()
+
+	wf := NewWorkflow("test_bufferstats_wf", 4)
+	words := wf.NewProc("words", "echo {p:word} > {o:out}")
+	words.InParam("word").SetBufferSize(1)
+	words.InParam("word").FromStr("hey", "how", "hoo")
+	words.SetOut("out", ".tmp/{p:word}.txt")
+
+	upper := wf.NewProc("upper", "tr a-z A-Z < {i:in} > {o:out}")
+	upper.In("in").SetBufferSize(8)
+	upper.In("in").From(words.Out("out"))
+	upper.SetOut("out", "{i:in|%.txt}.upper.txt")
+
+	wf.Run()
+
+	stats := wf.BufferStats()
+	assertEqualValues(t, 1, stats["words.word"].Size, "Wrong buffer size for words.word")
+	assertEqualValues(t, 3, stats["words.word"].Sends, "Wrong number of sends for words.word")
+	assertEqualValues(t, 8, stats["upper.in"].Size, "Wrong buffer size for upper.in")
+	assertEqualValues(t, 3, stats["upper.in"].Sends, "Wrong number of sends for upper.in")
+
+	cleanFilePatterns(".tmp/*")
+}
+
+func TestSetBufferSizeFailsAfterConnecting(t *testing.T) {
+	ensureFailsProgram("TestSetBufferSizeFailsAfterConnecting", func() {
+		initTestLogs()
+		wf := NewWorkflow("test_setbuffersize_wf", 4)
+		words := wf.NewProc("words", "echo {p:word} > {o:out}")
+		words.InParam("word").FromStr("hey", "how", "hoo")
+		words.SetOut("out", ".tmp/{p:word}.txt")
+		upper := wf.NewProc("upper", "tr a-z A-Z < {i:in} > {o:out}")
+		upper.In("in").From(words.Out("out"))
+		upper.In("in").SetBufferSize(8)
+	}, t)
+}
+
+func TestSetParamBufferSizeFailsAfterConnecting(t *testing.T) {
+	ensureFailsProgram("TestSetParamBufferSizeFailsAfterConnecting", func() {
+		initTestLogs()
+		wf := NewWorkflow("test_setbuffersize_wf", 4)
+		words := wf.NewProc("words", "echo {p:word} > {o:out}")
+		words.InParam("word").FromStr("hey", "how", "hoo")
+		words.InParam("word").SetBufferSize(1)
+	}, t)
+}
+
 func TestOutPortName(t *testing.T) {
 	initTestLogs()
 
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Process is the central component in SciPipe after Workflow. Processes are
@@ -20,6 +22,24 @@ type Process struct {
 	Prepend        string
 	Spawn          bool
 	PortInfo       map[string]*PortInfo
+	// ForceRerun makes the process re-run its tasks even if their outputs
+	// already exist, after moving the old outputs aside, or deleting them if
+	// Workflow.DeleteOldOutputs is true (See also Workflow.RunFrom)
+	ForceRerun bool
+	// CombineMode decides how the IPs and parameters received on the ports
+	// of the process are combined into tasks (See CombineZip and
+	// CombineCross)
+	CombineMode CombineMode
+	// broadcastPorts are the in-ports and param in-ports set with Broadcast
+	broadcastPorts map[string]bool
+	// outPathPatterns keeps the path patterns set with SetOut, so that the
+	// placeholders in them can be validated before the workflow is run
+	outPathPatterns map[string]string
+	// customPathFuncs keeps track of out-ports for which a path function has
+	// been set with SetOutFunc, rather than using the default path function
+	customPathFuncs map[string]bool
+	stats           ProcessStats
+	statsLock       sync.Mutex
 }
 
 // ------------------------------------------------------------------------
@@ -29,18 +49,28 @@ type Process struct {
 // NewProc returns a new Process, and initializes its ports based on the
 // command pattern.
 func NewProc(workflow *Workflow, name string, cmd string) *Process {
+	p := newProc(workflow, name, cmd)
+	workflow.AddProc(p)
+	return p
+}
+
+// newProc returns a new Process, like NewProc, but without adding it to the
+// workflow, so that it can be added to a SubWorkflow instead
+func newProc(workflow *Workflow, name string, cmd string) *Process {
 	p := &Process{
 		BaseProcess: NewBaseProcess(
 			workflow,
 			name,
 		),
-		CommandPattern: cmd,
-		PathFuncs:      make(map[string]func(*Task) string),
-		Spawn:          true,
-		CoresPerTask:   1,
-		PortInfo:       map[string]*PortInfo{},
+		CommandPattern:  cmd,
+		PathFuncs:       make(map[string]func(*Task) string),
+		Spawn:           true,
+		CoresPerTask:    1,
+		PortInfo:        map[string]*PortInfo{},
+		outPathPatterns: map[string]string{},
+		customPathFuncs: map[string]bool{},
+		broadcastPorts:  map[string]bool{},
 	}
-	workflow.AddProc(p)
 	p.initPortsFromCmdPattern(cmd, nil)
 	p.initDefaultPathFuncs()
 	return p
@@ -170,6 +200,15 @@ func sortedStringMapKeys(kv map[string]string) []string {
 	return keys
 }
 
+func sortedBoolMapKeys(kv map[string]bool) []string {
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func sortedFileIPSliceMapKeys(kv map[string][]*FileIP) []string {
 	keys := []string{}
 	for k := range kv {
@@ -279,6 +318,7 @@ func (p *Process) SetOut(outPortName string, pathPattern string) {
 		}
 		return path
 	})
+	p.outPathPatterns[outPortName] = pathPattern
 }
 
 // SetOutFunc takes a function which produces a file path based on data
@@ -288,6 +328,8 @@ func (p *Process) SetOutFunc(outPortName string, pathFmtFunc func(task *Task) (p
 		p.InitOutPort(p, outPortName)
 	}
 	p.PathFuncs[outPortName] = pathFmtFunc
+	p.customPathFuncs[outPortName] = true
+	delete(p.outPathPatterns, outPortName)
 }
 
 // ------------------------------------------------------------------------
@@ -301,6 +343,8 @@ func (p *Process) SetOutFunc(outPortName string, pathFmtFunc func(task *Task) (p
 // Task.Execute, not here.
 func (p *Process) Run() {
 	defer p.CloseOutPorts()
+	p.updateStats(func(s *ProcessStats) { s.Started = true })
+	defer p.updateStats(func(s *ProcessStats) { s.Finished = true })
 	// Check that CoresPerTask is a sane number
 	if p.CoresPerTask > cap(p.workflow.concurrentTasks) {
 		p.Failf("CoresPerTask (%d) can't be greater than maxConcurrentTasks of workflow (%d)", p.CoresPerTask, cap(p.workflow.concurrentTasks))
@@ -334,6 +378,7 @@ func (p *Process) Run() {
 				}
 
 				// Execute task in separate go-routine
+				p.workflow.taskScheduled(t)
 				go t.Execute()
 
 				startedTasks = append(startedTasks, t)
@@ -357,53 +402,428 @@ func (p *Process) Run() {
 }
 
 // createTasks is a helper method for Run that creates tasks based on incoming
-// IPs on in-ports, and feeds them to the Run method on the returned channel ch
+// IPs on in-ports, and feeds them to the Run method on the returned channel
+// ch. How IPs and parameters from different ports are combined into tasks is
+// decided by CombineMode and the broadcast ports (See Broadcast).
 func (p *Process) createTasks() (ch chan *Task) {
 	ch = make(chan *Task)
 	go func() {
 		defer close(ch)
 
-		inIPs := map[string]*FileIP{}
-		params := map[string]string{}
+		broadcastIPs, broadcastParams, buffered, ok := p.receiveOnBroadcastPorts()
+		if !ok {
+			// No tasks will be created, but upstream processes must not be
+			// left blocked, sending on the other ports
+			p.drainCombinedPorts(buffered)
+			return
+		}
+
+		if p.CombineMode == CombineCross {
+			inIPSets, paramSets := p.receiveAllOnCombinedPorts(buffered)
+			forEachCombination(inIPSets, paramSets, func(inIPs map[string]*FileIP, params map[string]string) {
+				ch <- p.newTaskFromInputs(mergeIPMaps(inIPs, broadcastIPs), mergeParamMaps(params, broadcastParams))
+			})
+			return
+		}
 
-		inPortsOpen := true
-		paramPortsOpen := true
 		for {
-			// Tags need to be per Task, otherwise they are overwritten by future IPs
-			tags := map[string]string{}
-			// Only read on in-ports if we have any
-			if len(p.inPorts) > 0 {
-				inIPs, inPortsOpen = p.receiveOnInPorts()
-				// If in-port is closed, that means we got the last params on last iteration, so break
-				if !inPortsOpen {
-					break
+			inIPs, params, portsOpen := p.receiveOnCombinedPorts(buffered)
+			// If any port is closed, that means we got the last inputs on last
+			// iteration, so break
+			if !portsOpen {
+				break
+			}
+
+			// Create task and send on the channel we are about to return
+			ch <- p.newTaskFromInputs(mergeIPMaps(inIPs, broadcastIPs), mergeParamMaps(params, broadcastParams))
+
+			// If we have no in-ports nor param in-ports, except broadcast
+			// ports, we should break after the first iteration
+			if len(p.combinedInPorts()) == 0 && len(p.combinedInParamPorts()) == 0 {
+				break
+			}
+		}
+	}()
+	return ch
+}
+
+// newTaskFromInputs creates a new task from the IPs and parameters received
+// on the in-ports and param in-ports of the process
+func (p *Process) newTaskFromInputs(inIPs map[string]*FileIP, params map[string]string) *Task {
+	// Tags need to be per Task, otherwise they are overwritten by future IPs
+	tags := map[string]string{}
+	for iname, ip := range inIPs {
+		for k, v := range ip.Tags() {
+			tags[iname+"."+k] = v
+		}
+	}
+	t := NewTask(p.workflow, p, p.Name(), p.CommandPattern, inIPs, p.PathFuncs, p.PortInfo, params, tags, p.Prepend, p.CustomExecute, p.CoresPerTask)
+	p.updateStats(func(s *ProcessStats) { s.TasksCreated++ })
+	return t
+}
+
+// ------------------------------------------------------------------------
+// Combining inputs from several ports
+// ------------------------------------------------------------------------
+
+// CombineMode decides how the IPs and parameters received on the in-ports and
+// param in-ports of a Process are combined into tasks
+type CombineMode int
+
+const (
+	// CombineZip creates one task for each set of IPs and parameters
+	// received, in order, one from each port. This is the default.
+	CombineZip CombineMode = iota
+	// CombineCross creates one task for every combination of the IPs and
+	// parameters received on the ports (the cartesian product). Since all
+	// inputs need to be received before the combinations are known, no tasks
+	// are created until all ports have been closed.
+	CombineCross
+)
+
+// Broadcast marks the in-ports or param in-ports named portNames as broadcast
+// ports, which receive a single IP or parameter, that is reused for every
+// task of the process, regardless of CombineMode. This can be used for
+// example for a reference genome, used together with each of a number of
+// samples.
+func (p *Process) Broadcast(portNames ...string) {
+	for _, portName := range portNames {
+		p.broadcastPorts[portName] = true
+	}
+}
+
+// combinedInPorts returns the in-ports that are not broadcast ports
+func (p *Process) combinedInPorts() map[string]*InPort {
+	inPorts := map[string]*InPort{}
+	for portName, inPort := range p.InPorts() {
+		if !p.broadcastPorts[portName] {
+			inPorts[portName] = inPort
+		}
+	}
+	return inPorts
+}
+
+// combinedInParamPorts returns the param in-ports that are not broadcast ports
+func (p *Process) combinedInParamPorts() map[string]*InParamPort {
+	inParamPorts := map[string]*InParamPort{}
+	for portName, inParamPort := range p.InParamPorts() {
+		if !p.broadcastPorts[portName] {
+			inParamPorts[portName] = inParamPort
+		}
+	}
+	return inParamPorts
+}
+
+// combinedInputs holds the IPs and parameters received on the ports that are
+// not broadcast ports, while waiting for the broadcast ports (See
+// receiveOnBroadcastPorts), and which of those ports were closed meanwhile.
+type combinedInputs struct {
+	ips         map[string][]*FileIP
+	params      map[string][]string
+	closedPorts map[string]bool
+}
+
+// receiveOnBroadcastPorts receives the single IP or parameter on each of the
+// broadcast ports. Any further values received on them are drained, with a
+// warning, so that upstream processes are not blocked. If a broadcast port is
+// closed without having received anything, ok is false.
+//
+// While waiting, the ports that are not broadcast ports are read into
+// buffered, since the broadcast value might be sent by a process downstream
+// of the same process that sends to those ports, which would otherwise be
+// blocked when their buffers are full.
+func (p *Process) receiveOnBroadcastPorts() (ips map[string]*FileIP, params map[string]string, buffered *combinedInputs, ok bool) {
+	ips = map[string]*FileIP{}
+	params = map[string]string{}
+	ok = true
+	mx := sync.Mutex{}
+
+	stopBuffering := make(chan struct{})
+	buffered, bufferingDone := p.bufferCombinedPorts(stopBuffering)
+
+	wg := &sync.WaitGroup{}
+	for portName, inPort := range p.InPorts() {
+		if !p.broadcastPorts[portName] {
+			continue
+		}
+		wg.Add(1)
+		go func(portName string, inPort *InPort) {
+			ip, open := <-inPort.Chan
+			mx.Lock()
+			if !open {
+				Warning.Printf("[Process:%s] Broadcast in-port (%s) was closed without receiving any IP, so no tasks will be created\n", p.Name(), portName)
+				ok = false
+			} else {
+				ips[portName] = ip
+			}
+			mx.Unlock()
+			wg.Done()
+			for extra := range inPort.Chan {
+				Warning.Printf("[Process:%s] Broadcast in-port (%s) received more than one IP, so ignoring: %s\n", p.Name(), portName, extra.Path())
+			}
+		}(portName, inPort)
+	}
+	for portName, inParamPort := range p.InParamPorts() {
+		if !p.broadcastPorts[portName] {
+			continue
+		}
+		wg.Add(1)
+		go func(portName string, inParamPort *InParamPort) {
+			param, open := <-inParamPort.Chan
+			mx.Lock()
+			if !open {
+				Warning.Printf("[Process:%s] Broadcast param in-port (%s) was closed without receiving any parameter, so no tasks will be created\n", p.Name(), portName)
+				ok = false
+			} else {
+				params[portName] = param
+			}
+			mx.Unlock()
+			wg.Done()
+			for extra := range inParamPort.Chan {
+				Warning.Printf("[Process:%s] Broadcast param in-port (%s) received more than one parameter, so ignoring: %s\n", p.Name(), portName, extra)
+			}
+		}(portName, inParamPort)
+	}
+	wg.Wait()
+
+	close(stopBuffering)
+	bufferingDone.Wait()
+	return ips, params, buffered, ok
+}
+
+// bufferCombinedPorts receives IPs and parameters on the ports that are not
+// broadcast ports into buffered, until stop is closed or the ports are
+// closed. The returned wait group is done when no more values will be added.
+func (p *Process) bufferCombinedPorts(stop chan struct{}) (buffered *combinedInputs, done *sync.WaitGroup) {
+	buffered = &combinedInputs{
+		ips:         map[string][]*FileIP{},
+		params:      map[string][]string{},
+		closedPorts: map[string]bool{},
+	}
+	mx := sync.Mutex{}
+	done = &sync.WaitGroup{}
+	for portName, inPort := range p.combinedInPorts() {
+		done.Add(1)
+		go func(portName string, inPort *InPort) {
+			defer done.Done()
+			for {
+				select {
+				case ip, open := <-inPort.Chan:
+					mx.Lock()
+					if !open {
+						buffered.closedPorts[portName] = true
+						mx.Unlock()
+						return
+					}
+					buffered.ips[portName] = append(buffered.ips[portName], ip)
+					mx.Unlock()
+				case <-stop:
+					return
 				}
 			}
-			// Only read on param in-ports if we have any
-			if len(p.inParamPorts) > 0 {
-				params, paramPortsOpen = p.receiveOnInParamPorts()
-				// If param-port is closed, that means we got the last params on last iteration, so break
-				if !paramPortsOpen {
-					break
+		}(portName, inPort)
+	}
+	for portName, inParamPort := range p.combinedInParamPorts() {
+		done.Add(1)
+		go func(portName string, inParamPort *InParamPort) {
+			defer done.Done()
+			for {
+				select {
+				case param, open := <-inParamPort.Chan:
+					mx.Lock()
+					if !open {
+						buffered.closedPorts[portName] = true
+						mx.Unlock()
+						return
+					}
+					buffered.params[portName] = append(buffered.params[portName], param)
+					mx.Unlock()
+				case <-stop:
+					return
 				}
 			}
+		}(portName, inParamPort)
+	}
+	return buffered, done
+}
 
-			for iname, ip := range inIPs {
-				for k, v := range ip.Tags() {
-					tags[iname+"."+k] = v
+// receiveOnCombinedPorts receives one IP or parameter on each of the ports
+// that are not broadcast ports, taking the ones in buffered first.
+// portsOpen is false if any of them is closed.
+func (p *Process) receiveOnCombinedPorts(buffered *combinedInputs) (ips map[string]*FileIP, params map[string]string, portsOpen bool) {
+	ips = map[string]*FileIP{}
+	params = map[string]string{}
+	portsOpen = true
+	for portName, inPort := range p.combinedInPorts() {
+		if len(buffered.ips[portName]) > 0 {
+			ips[portName], buffered.ips[portName] = buffered.ips[portName][0], buffered.ips[portName][1:]
+			continue
+		}
+		if buffered.closedPorts[portName] {
+			portsOpen = false
+			continue
+		}
+		Debug.Printf("[Process:%s]: Receieving on inPort (%s) ...", p.Name(), portName)
+		ip, open := <-inPort.Chan
+		if !open {
+			buffered.closedPorts[portName] = true
+			portsOpen = false
+			continue
+		}
+		ips[portName] = ip
+	}
+	// If an in-port is closed, we should not wait for more params
+	if !portsOpen {
+		return ips, params, portsOpen
+	}
+	for portName, inParamPort := range p.combinedInParamPorts() {
+		if len(buffered.params[portName]) > 0 {
+			params[portName], buffered.params[portName] = buffered.params[portName][0], buffered.params[portName][1:]
+			continue
+		}
+		if buffered.closedPorts[portName] {
+			portsOpen = false
+			continue
+		}
+		param, open := <-inParamPort.Chan
+		if !open {
+			buffered.closedPorts[portName] = true
+			portsOpen = false
+			continue
+		}
+		params[portName] = param
+	}
+	return ips, params, portsOpen
+}
+
+// receiveAllOnCombinedPorts receives all IPs and parameters on the ports that
+// are not broadcast ports, until they are closed, starting with the ones in
+// buffered. The ports are read concurrently, so that upstream processes
+// sending on more than one of them are not blocked.
+func (p *Process) receiveAllOnCombinedPorts(buffered *combinedInputs) (ipSets map[string][]*FileIP, paramSets map[string][]string) {
+	ipSets = map[string][]*FileIP{}
+	paramSets = map[string][]string{}
+	mx := sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for portName, inPort := range p.combinedInPorts() {
+		wg.Add(1)
+		go func(portName string, inPort *InPort, ips []*FileIP, closed bool) {
+			defer wg.Done()
+			if !closed {
+				for ip := range inPort.Chan {
+					ips = append(ips, ip)
+				}
+			}
+			mx.Lock()
+			ipSets[portName] = ips
+			mx.Unlock()
+		}(portName, inPort, append([]*FileIP{}, buffered.ips[portName]...), buffered.closedPorts[portName])
+	}
+	for portName, inParamPort := range p.combinedInParamPorts() {
+		wg.Add(1)
+		go func(portName string, inParamPort *InParamPort, params []string, closed bool) {
+			defer wg.Done()
+			if !closed {
+				for param := range inParamPort.Chan {
+					params = append(params, param)
 				}
 			}
+			mx.Lock()
+			paramSets[portName] = params
+			mx.Unlock()
+		}(portName, inParamPort, append([]string{}, buffered.params[portName]...), buffered.closedPorts[portName])
+	}
+	wg.Wait()
+	return ipSets, paramSets
+}
 
-			// Create task and send on the channel we are about to return
-			ch <- NewTask(p.workflow, p, p.Name(), p.CommandPattern, inIPs, p.PathFuncs, p.PortInfo, params, tags, p.Prepend, p.CustomExecute, p.CoresPerTask)
+// drainCombinedPorts receives and discards all IPs and parameters on the
+// ports that are not broadcast ports, including the ones in buffered, until
+// they are closed
+func (p *Process) drainCombinedPorts(buffered *combinedInputs) {
+	ipSets, paramSets := p.receiveAllOnCombinedPorts(buffered)
+	for portName, ips := range ipSets {
+		for _, ip := range ips {
+			Warning.Printf("[Process:%s] No task created, so ignoring IP received on in-port (%s): %s\n", p.Name(), portName, ip.Path())
+		}
+	}
+	for portName, params := range paramSets {
+		for _, param := range params {
+			Warning.Printf("[Process:%s] No task created, so ignoring parameter received on param in-port (%s): %s\n", p.Name(), portName, param)
+		}
+	}
+}
+
+// forEachCombination calls f with every combination of one IP from each of
+// the IP sets, and one parameter from each of the parameter sets. The
+// combinations are produced in a deterministic order, with the last port
+// (sorted by name, params after IPs) varying fastest. If any set is empty,
+// there are no combinations.
+func forEachCombination(ipSets map[string][]*FileIP, paramSets map[string][]string, f func(ips map[string]*FileIP, params map[string]string)) {
+	ipPortNames := []string{}
+	for portName := range ipSets {
+		ipPortNames = append(ipPortNames, portName)
+	}
+	sort.Strings(ipPortNames)
+	paramPortNames := []string{}
+	for portName := range paramSets {
+		paramPortNames = append(paramPortNames, portName)
+	}
+	sort.Strings(paramPortNames)
+
+	// sizes holds the number of values for each port, IP ports first
+	sizes := []int{}
+	for _, portName := range ipPortNames {
+		sizes = append(sizes, len(ipSets[portName]))
+	}
+	for _, portName := range paramPortNames {
+		sizes = append(sizes, len(paramSets[portName]))
+	}
+	for _, size := range sizes {
+		if size == 0 {
+			return
+		}
+	}
+
+	idxs := make([]int, len(sizes))
+	for {
+		ips := map[string]*FileIP{}
+		for i, portName := range ipPortNames {
+			ips[portName] = ipSets[portName][idxs[i]]
+		}
+		params := map[string]string{}
+		for i, portName := range paramPortNames {
+			params[portName] = paramSets[portName][idxs[len(ipPortNames)+i]]
+		}
+		f(ips, params)
 
-			// If we have no in-ports nor param in-ports, we should break after the first iteration
-			if len(p.inPorts) == 0 && len(p.inParamPorts) == 0 {
+		// Increment the indexes, odometer style
+		i := len(idxs) - 1
+		for ; i >= 0; i-- {
+			idxs[i]++
+			if idxs[i] < sizes[i] {
 				break
 			}
+			idxs[i] = 0
 		}
-	}()
-	return ch
+		if i < 0 {
+			return
+		}
+	}
+}
+
+func mergeIPMaps(a map[string]*FileIP, b map[string]*FileIP) map[string]*FileIP {
+	for k, v := range b {
+		a[k] = v
+	}
+	return a
+}
+
+func mergeParamMaps(a map[string]string, b map[string]string) map[string]string {
+	for k, v := range b {
+		a[k] = v
+	}
+	return a
 }
 
 type taskQueue []*Task
@@ -416,3 +836,56 @@ func (tq taskQueue) NextTaskDone() chan int {
 	}
 	return nil
 }
+
+// ------------------------------------------------------------------------
+// Statistics
+// ------------------------------------------------------------------------
+
+// ProcessStats contains statistics about the tasks of a process, collected
+// while the workflow is running
+type ProcessStats struct {
+	// Started is true when the process has started running
+	Started bool
+	// Finished is true when all the tasks of the process are done
+	Finished bool
+	// TasksCreated is the number of tasks created so far
+	TasksCreated int
+	// TasksRunning is the number of tasks currently executing
+	TasksRunning int
+	// TasksRun is the number of tasks executed so far
+	TasksRun int
+	// TasksSkipped is the number of tasks skipped, because their outputs
+	// already existed
+	TasksSkipped int
+	// TasksFailed is the number of tasks that have failed
+	TasksFailed int
+	// Runtime is the total execution time of the executed tasks
+	Runtime time.Duration
+	// FirstTaskStarted is the time when the first task of the process
+	// started executing
+	FirstTaskStarted time.Time
+	// LastTaskFinished is the time when the latest task of the process
+	// finished executing
+	LastTaskFinished time.Time
+}
+
+// TasksQueued returns the number of tasks created, but not yet started,
+// skipped or failed
+func (s ProcessStats) TasksQueued() int {
+	return s.TasksCreated - s.TasksRunning - s.TasksRun - s.TasksSkipped - s.TasksFailed
+}
+
+// Stats returns a snapshot of the statistics about the tasks of the process
+func (p *Process) Stats() ProcessStats {
+	p.statsLock.Lock()
+	defer p.statsLock.Unlock()
+	return p.stats
+}
+
+// updateStats updates the statistics of the process with the function
+// update, while holding the lock for the statistics
+func (p *Process) updateStats(update func(s *ProcessStats)) {
+	p.statsLock.Lock()
+	defer p.statsLock.Unlock()
+	update(&p.stats)
+}
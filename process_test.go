@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestNewProc(t *testing.T) {
@@ -150,3 +151,161 @@ func TestEnsureFailOnSamePortNameMultipleTypes(t *testing.T) {
 		p2.In("foo").From(p1.Out("out"))
 	}, t)
 }
+
+func TestCombineModeCross(t *testing.T) {
+	initTestLogs()
+
+	wf := NewWorkflow("TestCombineModeCross_WF", 4)
+
+	refs := wf.NewProc("refs", "echo {p:ref} > {o:out}")
+	refs.InParam("ref").From(NewParamSource(wf, "ref_src", "r1", "r2").Out())
+	refs.SetOut("out", ".tmp/{p:ref}.ref")
+
+	samples := wf.NewProc("samples", "echo {p:sample} > {o:out}")
+	samples.InParam("sample").From(NewParamSource(wf, "sample_src", "s1", "s2", "s3").Out())
+	samples.SetOut("out", ".tmp/{p:sample}.sample")
+
+	align := wf.NewProc("align", "cat {i:ref} {i:sample} > {o:out} # {p:mode}")
+	align.CombineMode = CombineCross
+	align.In("ref").From(refs.Out("out"))
+	align.In("sample").From(samples.Out("out"))
+	align.InParam("mode").From(NewParamSource(wf, "mode_src", "fast", "slow").Out())
+	align.SetOut("out", "{i:sample|%.sample}.{i:ref|basename|%.ref}.{p:mode}.aligned")
+
+	wf.Run()
+
+	for _, ref := range []string{"r1", "r2"} {
+		for _, sample := range []string{"s1", "s2", "s3"} {
+			for _, mode := range []string{"fast", "slow"} {
+				path := ".tmp/" + sample + "." + ref + "." + mode + ".aligned"
+				dat, err := ioutil.ReadFile(path)
+				assertNil(t, err, "File missing: "+path)
+				assertEqualValues(t, ref+"\n"+sample+"\n", string(dat), "Wrong content in file: "+path)
+			}
+		}
+	}
+	assertEqualValues(t, 12, align.Stats().TasksCreated, "Wrong number of tasks created")
+
+	cleanFilePatterns(".tmp/*")
+}
+
+func TestBroadcastPorts(t *testing.T) {
+	initTestLogs()
+
+	wf := NewWorkflow("TestBroadcastPorts_WF", 4)
+
+	ref := wf.NewProc("ref", "echo ref > {o:out}")
+	ref.SetOut("out", ".tmp/genome.ref")
+
+	samples := wf.NewProc("samples", "echo {p:sample} > {o:out}")
+	samples.InParam("sample").From(NewParamSource(wf, "sample_src", "s1", "s2", "s3").Out())
+	samples.SetOut("out", ".tmp/{p:sample}.sample")
+
+	align := wf.NewProc("align", "cat {i:ref} {i:sample} > {o:out} # {p:threads}")
+	align.Broadcast("ref", "threads")
+	align.In("ref").From(ref.Out("out"))
+	align.In("sample").From(samples.Out("out"))
+	align.InParam("threads").FromStr("4")
+	align.SetOut("out", "{i:sample|%.sample}.{p:threads}.aligned")
+
+	wf.Run()
+
+	for _, sample := range []string{"s1", "s2", "s3"} {
+		path := ".tmp/" + sample + ".4.aligned"
+		dat, err := ioutil.ReadFile(path)
+		assertNil(t, err, "File missing: "+path)
+		assertEqualValues(t, "ref\n"+sample+"\n", string(dat), "Wrong content in file: "+path)
+	}
+
+	cleanFilePatterns(".tmp/*")
+}
+
+func TestBroadcastPortClosedWithoutValue(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	wf := NewWorkflow("TestBroadcastPortClosedWithoutValue_WF", 4)
+
+	// More samples than the port buffer holds, so that the sample source
+	// blocks unless the port is drained
+	align := wf.NewProc("align", "echo {p:ref} {p:sample} > {o:out}")
+	align.Broadcast("ref")
+	align.InParam("ref").From(NewParamSource(wf, "ref_src").Out())
+	align.InParam("sample").From(NewParamSource(wf, "sample_src", moreSamplesThanBufsize()...).Out())
+	align.SetOut("out", dir+"/{p:sample}.aligned")
+
+	runWithTimeout(t, wf, 10*time.Second)
+	assertEqualValues(t, 0, align.Stats().TasksCreated, "Wrong number of tasks created")
+}
+
+func TestBroadcastPortFromSameStream(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	wf := NewWorkflow("TestBroadcastPortFromSameStream_WF", 4)
+
+	// The broadcast parameter (the number of samples) is only sent after all
+	// samples have been sent on the other port, which is more than its buffer
+	// holds, so the other port has to be read while waiting for it
+	samples := moreSamplesThanBufsize()
+	sampleSrc := NewParamSource(wf, "sample_src", samples...)
+	counter := NewParamCounter(wf, "counter")
+	counter.In().From(sampleSrc.Out())
+
+	label := wf.NewProc("label", "echo {p:sample} of {p:count} > {o:out}")
+	label.Broadcast("count")
+	label.InParam("count").From(counter.Out())
+	label.InParam("sample").From(sampleSrc.Out())
+	label.SetOut("out", dir+"/{p:sample}.txt")
+
+	runWithTimeout(t, wf, 30*time.Second)
+	assertEqualValues(t, len(samples), label.Stats().TasksRun, "Wrong number of tasks run")
+	dat, err := ioutil.ReadFile(dir + "/s0.txt")
+	assertNil(t, err)
+	assertEqualValues(t, "s0 of "+strconv.Itoa(len(samples))+"\n", string(dat))
+}
+
+// moreSamplesThanBufsize returns sample names for more samples than the
+// buffer of a port holds
+func moreSamplesThanBufsize() []string {
+	samples := []string{}
+	for i := 0; i < getBufsize()+10; i++ {
+		samples = append(samples, "s"+strconv.Itoa(i))
+	}
+	return samples
+}
+
+// runWithTimeout runs the workflow, and fails the test if it does not finish
+// within timeout
+func runWithTimeout(t *testing.T, wf *Workflow, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wf.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("Workflow %s did not finish within %s", wf.Name(), timeout)
+	}
+}
+
+func TestForEachCombination(t *testing.T) {
+	combinations := []string{}
+	forEachCombination(
+		map[string][]*FileIP{},
+		map[string][]string{"b": {"1", "2"}, "a": {"x", "y"}},
+		func(ips map[string]*FileIP, params map[string]string) {
+			combinations = append(combinations, params["a"]+params["b"])
+		})
+	assertEqualValues(t, []string{"x1", "x2", "y1", "y2"}, combinations)
+
+	combinations = []string{}
+	forEachCombination(
+		map[string][]*FileIP{"in": {}},
+		map[string][]string{"a": {"x"}},
+		func(ips map[string]*FileIP, params map[string]string) {
+			combinations = append(combinations, params["a"])
+		})
+	assertEqualValues(t, []string{}, combinations, "Expected no combinations when a port has no values")
+}
@@ -0,0 +1,224 @@
+package scipipe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Progress display
+// ----------------------------------------------------------------------------
+
+const (
+	// progressRefreshInterval is how often the live progress view is redrawn
+	progressRefreshInterval = 500 * time.Millisecond
+	// progressSummaryInterval is how often a plain text progress summary is
+	// printed, when stdout is not a terminal
+	progressSummaryInterval = 30 * time.Second
+)
+
+// progressDisplay shows the number of queued, running, done and skipped tasks
+// of each process in a workflow, based on the statistics collected by the
+// processes and tasks (See ProcessStats). If live is true, the progress is
+// shown as a table that is redrawn in place, below any log output. Otherwise,
+// a plain text summary is printed periodically.
+type progressDisplay struct {
+	wf         *Workflow
+	out        io.Writer
+	live       bool
+	interval   time.Duration
+	startTime  time.Time
+	linesDrawn int
+	lock       sync.Mutex
+	stopChan   chan struct{}
+	stopped    chan struct{}
+	loggers    []*log.Logger
+	logWriters []io.Writer
+}
+
+func newProgressDisplay(wf *Workflow, out io.Writer, live bool) *progressDisplay {
+	interval := progressSummaryInterval
+	if live {
+		interval = progressRefreshInterval
+	}
+	return &progressDisplay{
+		wf:       wf,
+		out:      out,
+		live:     live,
+		interval: interval,
+		stopChan: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// start starts updating the progress display in a separate go-routine, until
+// stop is called
+func (pd *progressDisplay) start() {
+	pd.startTime = time.Now()
+	if pd.live {
+		pd.redirectLogs()
+	}
+	go func() {
+		defer close(pd.stopped)
+		ticker := time.NewTicker(pd.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				pd.update()
+			case <-pd.stopChan:
+				pd.update()
+				if pd.live {
+					pd.restoreLogs()
+				}
+				return
+			}
+		}
+	}()
+}
+
+// stop shows the final progress, and stops updating the progress display
+func (pd *progressDisplay) stop() {
+	close(pd.stopChan)
+	<-pd.stopped
+}
+
+// update redraws the live progress view, or prints a progress summary
+func (pd *progressDisplay) update() {
+	pd.lock.Lock()
+	defer pd.lock.Unlock()
+	if pd.live {
+		pd.clear()
+		pd.draw()
+		return
+	}
+	fmt.Fprintf(pd.out, "%s %s", time.Now().Format("2006/01/02 15:04:05"), pd.table())
+}
+
+// clear removes the previously drawn progress view from the terminal
+func (pd *progressDisplay) clear() {
+	if pd.linesDrawn > 0 {
+		fmt.Fprintf(pd.out, "\033[%dA\033[J", pd.linesDrawn)
+		pd.linesDrawn = 0
+	}
+}
+
+// draw draws the progress view at the current position in the terminal
+func (pd *progressDisplay) draw() {
+	table := pd.table()
+	fmt.Fprint(pd.out, table)
+	pd.linesDrawn = strings.Count(table, "\n")
+}
+
+// table returns the progress of the workflow, and of each of its processes,
+// formatted as a table
+func (pd *progressDisplay) table() string {
+	now := time.Now()
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Workflow %s: %s elapsed\n", pd.wf.Name(), now.Sub(pd.startTime).Round(time.Second))
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Process\tQueued\tRunning\tDone\tSkipped\tFailed\tElapsed\tETA\t")
+	for _, p := range progressProcs(pd.wf.ProcsSorted()) {
+		s := p.Stats()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t\n", p.Name(), s.TasksQueued(), s.TasksRunning, s.TasksRun, s.TasksSkipped, s.TasksFailed, s.elapsed(now), s.eta(now))
+	}
+	tw.Flush()
+	return buf.String()
+}
+
+// progressProcs returns the processes (of type *Process) among procs, and in
+// any sub-workflows among them, since other processes do not collect task
+// statistics
+func progressProcs(procs []WorkflowProcess) []*Process {
+	ps := []*Process{}
+	for _, proc := range procs {
+		switch p := proc.(type) {
+		case *Process:
+			ps = append(ps, p)
+		case *SubWorkflow:
+			ps = append(ps, progressProcs(p.ProcsSorted())...)
+		}
+	}
+	return ps
+}
+
+// elapsed returns the time since the first task of the process started, until
+// now, or until the last task finished if the process is finished, formatted
+// for the progress display
+func (s ProcessStats) elapsed(now time.Time) string {
+	if s.FirstTaskStarted.IsZero() {
+		return "-"
+	}
+	if s.Finished {
+		now = s.LastTaskFinished
+	}
+	return now.Sub(s.FirstTaskStarted).Round(time.Second).String()
+}
+
+// eta returns the estimated time until the queued and running tasks of the
+// process are done, based on the rate at which tasks have been run so far,
+// formatted for the progress display. Note that tasks not yet created, since
+// their inputs are not yet received, are not included.
+func (s ProcessStats) eta(now time.Time) string {
+	if s.Finished {
+		return "done"
+	}
+	remaining := s.TasksQueued() + s.TasksRunning
+	if s.TasksRun == 0 || remaining == 0 {
+		return "-"
+	}
+	elapsed := now.Sub(s.FirstTaskStarted)
+	return (elapsed / time.Duration(s.TasksRun) * time.Duration(remaining)).Round(time.Second).String()
+}
+
+// redirectLogs makes the loggers write through the progress display, so that
+// log lines are printed above the live progress view, rather than mixed into
+// it
+func (pd *progressDisplay) redirectLogs() {
+	for _, lg := range []*log.Logger{Info, Audit, Warning, Error} {
+		if lg == nil {
+			continue
+		}
+		pd.loggers = append(pd.loggers, lg)
+		pd.logWriters = append(pd.logWriters, lg.Writer())
+		lg.SetOutput(&progressLogWriter{pd: pd, out: lg.Writer()})
+	}
+}
+
+// restoreLogs restores the outputs of the loggers redirected by redirectLogs
+func (pd *progressDisplay) restoreLogs() {
+	for i, lg := range pd.loggers {
+		lg.SetOutput(pd.logWriters[i])
+	}
+}
+
+// progressLogWriter writes log output above the live progress view
+type progressLogWriter struct {
+	pd  *progressDisplay
+	out io.Writer
+}
+
+func (w *progressLogWriter) Write(p []byte) (n int, err error) {
+	w.pd.lock.Lock()
+	defer w.pd.lock.Unlock()
+	w.pd.clear()
+	n, err = w.out.Write(p)
+	w.pd.draw()
+	return n, err
+}
+
+// isTerminal returns true if f is a terminal (character device)
+func isTerminal(f *os.File) bool {
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fileInfo.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,79 @@
+package scipipe
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressTable(t *testing.T) {
+	initTestLogs()
+
+	wf, _, _ := newFooBarTestWorkflow("TestProgressTable_WF", t.TempDir(), "a", "b", "c")
+	wf.Run()
+
+	buf := &bytes.Buffer{}
+	pd := newProgressDisplay(wf, buf, false)
+	pd.startTime = time.Now()
+	pd.update()
+
+	out := buf.String()
+	if !strings.Contains(out, "Workflow TestProgressTable_WF:") {
+		t.Errorf("Progress summary does not contain workflow name: %s", out)
+	}
+	// Queued, Running, Done, Skipped, Failed, Elapsed, ETA
+	for _, procName := range []string{"foo", "bar"} {
+		rowPtrn := regexp.MustCompile(procName + `\s+0\s+0\s+3\s+0\s+0\s+\S+\s+done`)
+		if !rowPtrn.MatchString(out) {
+			t.Errorf("Progress summary does not contain expected row for process %s: %s", procName, out)
+		}
+	}
+}
+
+func TestProgressLiveRedirectsLogs(t *testing.T) {
+	initTestLogs()
+
+	wf := NewWorkflow("TestProgressLive_WF", 4)
+	wf.NewProc("foo", "echo foo > {o:out}").SetOut("out", t.TempDir()+"/foo.txt")
+
+	logBuf := &bytes.Buffer{}
+	origWriter := Audit.Writer()
+	Audit.SetOutput(logBuf)
+	defer Audit.SetOutput(origWriter)
+
+	buf := &bytes.Buffer{}
+	pd := newProgressDisplay(wf, buf, true)
+	pd.start()
+	pd.update()
+	Audit.Println("Some log line")
+	pd.stop()
+
+	if !strings.Contains(logBuf.String(), "Some log line") {
+		t.Errorf("Log line not written to log output while showing progress: %s", logBuf.String())
+	}
+	if !strings.Contains(buf.String(), "\033[") {
+		t.Errorf("Live progress view was not redrawn: %q", buf.String())
+	}
+	if Audit.Writer() != logBuf {
+		t.Error("Log output was not restored after the progress display was stopped")
+	}
+}
+
+func TestProcessStatsETA(t *testing.T) {
+	now := time.Now()
+	stats := ProcessStats{
+		TasksCreated:     10,
+		TasksRunning:     2,
+		TasksRun:         4,
+		FirstTaskStarted: now.Add(-40 * time.Second),
+	}
+	assertEqualValues(t, 4, stats.TasksQueued())
+	// 4 tasks run in 40 seconds, with 6 remaining, gives 60 seconds left
+	assertEqualValues(t, "1m0s", stats.eta(now))
+	assertEqualValues(t, "40s", stats.elapsed(now))
+
+	stats.Finished = true
+	assertEqualValues(t, "done", stats.eta(now))
+}
@@ -0,0 +1,193 @@
+package scipipe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// W3C PROV export
+// ----------------------------------------------------------------------------
+
+const (
+	provNamespaceScipipe = "http://scipipe.org/prov#"
+	provNamespaceTask    = "http://scipipe.org/prov/task/"
+	provNamespaceFile    = "http://scipipe.org/prov/file/"
+	provAgentID          = "scipipe:scipipe"
+)
+
+// PROVDocument is a provenance document in the W3C PROV-JSON format (See
+// https://www.w3.org/Submission/prov-json/). Each record type maps
+// identifiers to the attributes of the records.
+type PROVDocument struct {
+	Prefix            map[string]string                 `json:"prefix"`
+	Entity            map[string]map[string]interface{} `json:"entity,omitempty"`
+	Activity          map[string]map[string]interface{} `json:"activity,omitempty"`
+	Agent             map[string]map[string]interface{} `json:"agent,omitempty"`
+	WasGeneratedBy    map[string]map[string]interface{} `json:"wasGeneratedBy,omitempty"`
+	Used              map[string]map[string]interface{} `json:"used,omitempty"`
+	WasAssociatedWith map[string]map[string]interface{} `json:"wasAssociatedWith,omitempty"`
+	WasDerivedFrom    map[string]map[string]interface{} `json:"wasDerivedFrom,omitempty"`
+}
+
+// NewPROVDocument converts the audit info auditInfo, and all its upstream
+// audit infos, into a W3C PROV document. Each task (audit info) becomes an
+// activity, with its command and params as attributes, associated with
+// SciPipe as a software agent. The files in OutFiles of the tasks, and in
+// their Upstream maps, become entities, that were generated by or used by
+// the activities, and the outputs of each task are recorded as derived from
+// its inputs. Upstream files not created by SciPipe (without audit info) only
+// become entities.
+func NewPROVDocument(auditInfo *AuditInfo) *PROVDocument {
+	doc := &PROVDocument{
+		Prefix: map[string]string{
+			"scipipe": provNamespaceScipipe,
+			"task":    provNamespaceTask,
+			"file":    provNamespaceFile,
+		},
+		Entity:            map[string]map[string]interface{}{},
+		Activity:          map[string]map[string]interface{}{},
+		Agent:             map[string]map[string]interface{}{},
+		WasGeneratedBy:    map[string]map[string]interface{}{},
+		Used:              map[string]map[string]interface{}{},
+		WasAssociatedWith: map[string]map[string]interface{}{},
+		WasDerivedFrom:    map[string]map[string]interface{}{},
+	}
+	doc.Agent[provAgentID] = map[string]interface{}{
+		"prov:type":       "prov:SoftwareAgent",
+		"prov:label":      "SciPipe",
+		"scipipe:version": Version,
+	}
+
+	for _, ai := range provAuditInfos(auditInfo) {
+		if !isTaskAuditInfo(ai) {
+			continue
+		}
+		actID := "task:" + ai.ID
+		act := map[string]interface{}{
+			"prov:type":           "scipipe:Task",
+			"prov:label":          ai.ProcessName,
+			"scipipe:processName": ai.ProcessName,
+			"scipipe:command":     ai.Command,
+		}
+		if !ai.StartTime.IsZero() {
+			act["prov:startTime"] = ai.StartTime.Format(time.RFC3339Nano)
+		}
+		if !ai.FinishTime.IsZero() {
+			act["prov:endTime"] = ai.FinishTime.Format(time.RFC3339Nano)
+		}
+		for name, value := range ai.Params {
+			act["scipipe:param_"+name] = value
+		}
+		for name, value := range ai.Tags {
+			act["scipipe:tag_"+name] = value
+		}
+		doc.Activity[actID] = act
+		doc.WasAssociatedWith[fmt.Sprintf("_:waw_%s", ai.ID)] = map[string]interface{}{
+			"prov:activity": actID,
+			"prov:agent":    provAgentID,
+		}
+
+		outIDs := []string{}
+		for _, portName := range sortedStringMapKeys(ai.OutFiles) {
+			path := ai.OutFiles[portName]
+			entID := doc.addFileEntity(path)
+			outIDs = append(outIDs, entID)
+			gen := map[string]interface{}{
+				"prov:entity":   entID,
+				"prov:activity": actID,
+				"prov:role":     "scipipe:out_" + portName,
+			}
+			if !ai.FinishTime.IsZero() {
+				gen["prov:time"] = ai.FinishTime.Format(time.RFC3339Nano)
+			}
+			doc.WasGeneratedBy[fmt.Sprintf("_:wgb_%s_%s", ai.ID, portName)] = gen
+		}
+		for i, path := range sortedAuditInfoMapKeys(ai.Upstream) {
+			entID := doc.addFileEntity(path)
+			doc.Used[fmt.Sprintf("_:u_%s_%d", ai.ID, i+1)] = map[string]interface{}{
+				"prov:activity": actID,
+				"prov:entity":   entID,
+			}
+			for j, outID := range outIDs {
+				doc.WasDerivedFrom[fmt.Sprintf("_:wdf_%s_%d_%d", ai.ID, i+1, j+1)] = map[string]interface{}{
+					"prov:generatedEntity": outID,
+					"prov:usedEntity":      entID,
+					"prov:activity":        actID,
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// JSON returns the document in the PROV-JSON format
+func (doc *PROVDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// addFileEntity adds an entity for the file at path, if not already added,
+// and returns its identifier
+func (doc *PROVDocument) addFileEntity(path string) string {
+	entID := "file:" + provEscapePath(path)
+	if _, ok := doc.Entity[entID]; !ok {
+		doc.Entity[entID] = map[string]interface{}{
+			"prov:type":    "scipipe:File",
+			"prov:label":   path,
+			"scipipe:path": path,
+		}
+	}
+	return entID
+}
+
+// provEscapePath escapes each part of path, so that it can be used as the
+// local part of a qualified name
+func provEscapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// isTaskAuditInfo returns true if ai is the audit info of a task, rather than
+// an empty audit info, as returned for files without an audit file
+func isTaskAuditInfo(ai *AuditInfo) bool {
+	return ai.ProcessName != "" || ai.Command != ""
+}
+
+// provAuditInfos returns ai and all its upstream audit infos, with each
+// audit info (by ID) included only once, sorted by start time and ID
+func provAuditInfos(ai *AuditInfo) []*AuditInfo {
+	byID := map[string]*AuditInfo{}
+	var visit func(ai *AuditInfo)
+	visit = func(ai *AuditInfo) {
+		if ai == nil {
+			return
+		}
+		if _, ok := byID[ai.ID]; ok {
+			return
+		}
+		byID[ai.ID] = ai
+		for _, up := range ai.Upstream {
+			visit(up)
+		}
+	}
+	visit(ai)
+
+	ais := []*AuditInfo{}
+	for _, ai := range byID {
+		ais = append(ais, ai)
+	}
+	sort.Slice(ais, func(i, j int) bool {
+		if ais[i].StartTime.Equal(ais[j].StartTime) {
+			return ais[i].ID < ais[j].ID
+		}
+		return ais[i].StartTime.Before(ais[j].StartTime)
+	})
+	return ais
+}
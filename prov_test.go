@@ -0,0 +1,68 @@
+package scipipe
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewPROVDocument(t *testing.T) {
+	startTime := time.Date(2018, 6, 27, 17, 50, 51, 0, time.UTC)
+	// raw.txt has no audit file, so gets an empty audit info
+	raw := NewAuditInfo()
+	foo := &AuditInfo{
+		ID:          "foo1",
+		ProcessName: "foo",
+		Command:     "sed 's/a/b/' raw.txt > data/foo out.txt",
+		Params:      map[string]string{"letter": "a"},
+		Tags:        map[string]string{},
+		StartTime:   startTime,
+		FinishTime:  startTime.Add(time.Second),
+		OutFiles:    map[string]string{"out": "data/foo out.txt"},
+		Upstream:    map[string]*AuditInfo{"raw.txt": raw},
+	}
+	bar := &AuditInfo{
+		ID:          "bar1",
+		ProcessName: "bar",
+		Command:     "cat 'data/foo out.txt' > bar.txt",
+		Params:      map[string]string{},
+		Tags:        map[string]string{"sample": "s1"},
+		StartTime:   startTime.Add(2 * time.Second),
+		FinishTime:  startTime.Add(3 * time.Second),
+		OutFiles:    map[string]string{"out": "bar.txt"},
+		Upstream:    map[string]*AuditInfo{"data/foo out.txt": foo},
+	}
+
+	doc := NewPROVDocument(bar)
+
+	assertEqualValues(t, 2, len(doc.Activity))
+	assertEqualValues(t, "a", doc.Activity["task:foo1"]["scipipe:param_letter"])
+	assertEqualValues(t, "s1", doc.Activity["task:bar1"]["scipipe:tag_sample"])
+	assertEqualValues(t, bar.Command, doc.Activity["task:bar1"]["scipipe:command"])
+	assertEqualValues(t, startTime.Format(time.RFC3339Nano), doc.Activity["task:foo1"]["prov:startTime"])
+
+	assertEqualValues(t, 3, len(doc.Entity))
+	for _, entID := range []string{"file:raw.txt", "file:data/foo%20out.txt", "file:bar.txt"} {
+		if _, ok := doc.Entity[entID]; !ok {
+			t.Errorf("Entity %s not found in PROV document: %v", entID, doc.Entity)
+		}
+	}
+
+	assertEqualValues(t, 2, len(doc.WasGeneratedBy))
+	assertEqualValues(t, 2, len(doc.Used))
+	assertEqualValues(t, 2, len(doc.WasDerivedFrom))
+	assertEqualValues(t, 2, len(doc.WasAssociatedWith))
+	derivation := doc.WasDerivedFrom["_:wdf_bar1_1_1"]
+	assertEqualValues(t, "file:bar.txt", derivation["prov:generatedEntity"])
+	assertEqualValues(t, "file:data/foo%20out.txt", derivation["prov:usedEntity"])
+
+	docJSON, err := doc.JSON()
+	assertNil(t, err)
+	parsed := map[string]interface{}{}
+	assertNil(t, json.Unmarshal(docJSON, &parsed))
+	for _, key := range []string{"prefix", "entity", "activity", "agent", "wasGeneratedBy", "used", "wasAssociatedWith", "wasDerivedFrom"} {
+		if _, ok := parsed[key]; !ok {
+			t.Errorf("Key %s missing in PROV-JSON", key)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package scipipe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// HTTP status API
+// ----------------------------------------------------------------------------
+
+// WorkflowStatus is the status of a workflow, as returned by the HTTP status
+// API (See ServeStatus)
+type WorkflowStatus struct {
+	Workflow     string
+	StartTime    time.Time
+	Elapsed      time.Duration
+	SlotsUsed    int
+	SlotsTotal   int
+	Processes    []ProcessStatus
+	Connections  []ConnectionStatus
+	RunningTasks []TaskStatus
+	// CompletedTasks are the most recently completed (or skipped) tasks, at
+	// most statusRecentTasks of them, oldest first, while TasksCompleted is
+	// the total number of completed tasks
+	CompletedTasks []TaskStatus
+	TasksCompleted int
+	FailedTasks    []TaskStatus
+	TasksFailed    int
+}
+
+// statusRecentTasks is the number of recently completed tasks kept for the
+// status API, so that long runs don't keep the status of every task in memory
+const statusRecentTasks = 100
+
+// ProcessStatus is the status of a process in the workflow. Stats are only
+// available for processes created with NewProc (of type *Process).
+type ProcessStatus struct {
+	Name          string
+	Type          string
+	Status        string
+	Stats         *ProcessStats
+	InPorts       []string
+	OutPorts      []string
+	InParamPorts  []string
+	OutParamPorts []string
+}
+
+// ConnectionStatus is the status of a connection between an out-port and an
+// in-port (or a param out-port and a param in-port)
+type ConnectionStatus struct {
+	From  string
+	To    string
+	Param bool
+	// Sent is the number of IPs or parameters sent over the connection
+	Sent int
+	// Open is true until the out-port has been closed
+	Open bool
+	// Buffered is the number of IPs or parameters currently waiting in the
+	// buffer of the in-port
+	Buffered int
+}
+
+// TaskStatus is the status of a task that is running, or has completed or
+// failed
+type TaskStatus struct {
+	Process    string
+	Command    string
+	Params     map[string]string
+	OutFiles   map[string]string
+	StartTime  time.Time
+	FinishTime time.Time
+	Skipped    bool
+	Error      string
+}
+
+// ServeStatus starts serving a JSON API with the status of the workflow, on
+// the TCP network address addr (such as "localhost:8080"), in the background.
+// It returns an error if the address can not be listened on. The following
+// endpoints are available:
+//
+//	/status     The full status (See WorkflowStatus)
+//	/processes  The processes, with their ports and task statistics
+//	/tasks      The running, recently completed, and failed tasks
+//	/metrics    Metrics in the Prometheus text format (See Metrics)
+func (wf *Workflow) ServeStatus(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("Could not listen on %s for status API: %v", addr, err)
+	}
+	wf.Auditf("Serving status API on http://%s/status", listener.Addr())
+	go func() {
+		err := http.Serve(listener, wf.StatusHandler())
+		if err != nil {
+			Error.Printf("[Workflow:%s] Status API stopped: %v\n", wf.Name(), err)
+		}
+	}()
+	return nil
+}
+
+// StatusHandler returns an http.Handler serving the status API described for
+// ServeStatus, so that it can be added to an existing HTTP server
+func (wf *Workflow) StatusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeStatusJSON(w, wf.Status())
+	})
+	mux.HandleFunc("/processes", func(w http.ResponseWriter, r *http.Request) {
+		status := wf.Status()
+		writeStatusJSON(w, struct {
+			Processes   []ProcessStatus
+			Connections []ConnectionStatus
+		}{status.Processes, status.Connections})
+	})
+	mux.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
+		status := wf.Status()
+		writeStatusJSON(w, struct {
+			RunningTasks   []TaskStatus
+			CompletedTasks []TaskStatus
+			TasksCompleted int
+			FailedTasks    []TaskStatus
+			TasksFailed    int
+		}{status.RunningTasks, status.CompletedTasks, status.TasksCompleted, status.FailedTasks, status.TasksFailed})
+	})
+	mux.Handle("/metrics", wf.MetricsHandler())
+	return mux
+}
+
+func writeStatusJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Status returns a snapshot of the status of the workflow, as served by the
+// HTTP status API
+func (wf *Workflow) Status() *WorkflowStatus {
+	status := &WorkflowStatus{
+		Workflow:       wf.Name(),
+		SlotsUsed:      len(wf.concurrentTasks),
+		SlotsTotal:     cap(wf.concurrentTasks),
+		Processes:      []ProcessStatus{},
+		Connections:    []ConnectionStatus{},
+		RunningTasks:   []TaskStatus{},
+		CompletedTasks: []TaskStatus{},
+		FailedTasks:    []TaskStatus{},
+	}
+
+	edges := wf.statusEdges()
+	sentByProc := map[string]int{}
+	for _, e := range edges {
+		sentByProc[e.fromProc] += e.sentCount()
+		cs := ConnectionStatus{
+			From:  e.fromProc + "." + e.fromPort,
+			To:    e.toProc + "." + e.toPort,
+			Param: e.isParam,
+			Sent:  e.sentCount(),
+		}
+		if e.isParam {
+			cs.Open = e.remoteParamPort.isConnectedTo(e.outParamPort)
+			cs.Buffered = len(e.remoteParamPort.Chan)
+		} else {
+			cs.Open = e.remotePort.isConnectedTo(e.outPort)
+			cs.Buffered = len(e.remotePort.Chan)
+		}
+		status.Connections = append(status.Connections, cs)
+	}
+	for _, p := range wf.statusProcs(wf.ProcsSorted()) {
+		status.Processes = append(status.Processes, newProcessStatus(p, sentByProc))
+	}
+
+	wf.tasksLock.Lock()
+	status.StartTime = wf.startTime
+	for _, ts := range wf.runningTasks {
+		status.RunningTasks = append(status.RunningTasks, *ts)
+	}
+	status.CompletedTasks = append(status.CompletedTasks, wf.recentTasks.list()...)
+	status.TasksCompleted = wf.tasksCompleted
+	status.FailedTasks = append(status.FailedTasks, wf.failedTasks...)
+	status.TasksFailed = len(wf.failedTasks)
+	wf.tasksLock.Unlock()
+
+	if !status.StartTime.IsZero() {
+		status.Elapsed = time.Since(status.StartTime)
+	}
+	sort.SliceStable(status.RunningTasks, func(i, j int) bool {
+		return status.RunningTasks[i].StartTime.Before(status.RunningTasks[j].StartTime)
+	})
+	return status
+}
+
+// statusProcs returns the processes among procs, with sub-workflows replaced
+// by their inner processes
+func (wf *Workflow) statusProcs(procs []WorkflowProcess) []WorkflowProcess {
+	result := []WorkflowProcess{}
+	for _, p := range procs {
+		if sw, ok := p.(*SubWorkflow); ok {
+			result = append(result, wf.statusProcs(sw.ProcsSorted())...)
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func newProcessStatus(p WorkflowProcess, sentByProc map[string]int) ProcessStatus {
+	ps := ProcessStatus{
+		Name:          p.Name(),
+		Type:          fmt.Sprintf("%T", p),
+		InPorts:       sortedInPortMapKeys(p.InPorts()),
+		OutPorts:      sortedOutPortMapKeys(p.OutPorts()),
+		InParamPorts:  sortedInParamPortMapKeys(p.InParamPorts()),
+		OutParamPorts: sortedOutParamPortMapKeys(p.OutParamPorts()),
+	}
+	if proc, ok := p.(*Process); ok {
+		stats := proc.Stats()
+		ps.Stats = &stats
+		ps.Status = stats.status()
+	} else if sentByProc[p.Name()] > 0 {
+		ps.Status = procStatusRun
+	} else {
+		ps.Status = procStatusNotReached
+	}
+	return ps
+}
+
+// ----------------------------------------------------------------------------
+// Task tracking for the status API
+// ----------------------------------------------------------------------------
+
+func newTaskStatus(t *Task) *TaskStatus {
+	ts := &TaskStatus{
+		Process:   t.Process.Name(),
+		Command:   t.Command,
+		Params:    map[string]string{},
+		OutFiles:  map[string]string{},
+		StartTime: t.startTime,
+	}
+	if t.CustomExecute != nil {
+		ts.Command = "(Custom Go function)"
+	}
+	for pname, pval := range t.Params {
+		ts.Params[pname] = pval
+	}
+	for oname, oip := range t.OutIPs {
+		ts.OutFiles[oname] = oip.Path()
+	}
+	return ts
+}
+
+// recordTaskStarted records that the task t has started executing
+func (wf *Workflow) recordTaskStarted(t *Task) {
+	wf.tasksLock.Lock()
+	defer wf.tasksLock.Unlock()
+	if wf.runningTasks == nil {
+		wf.runningTasks = map[*Task]*TaskStatus{}
+	}
+	wf.runningTasks[t] = newTaskStatus(t)
+	wf.procMetrics(t.Process.Name()).started++
+}
+
+// recordTaskFinished records that the task t has finished executing, or was
+// skipped since its outputs already existed. Only the most recent tasks are
+// kept for the status API, while all tasks are kept if needed for the trace
+// (See TraceFile), and the output paths if needed for the run history (See
+// HistoryFile).
+func (wf *Workflow) recordTaskFinished(t *Task) {
+	wf.tasksLock.Lock()
+	defer wf.tasksLock.Unlock()
+	ts, ok := wf.runningTasks[t]
+	if ok {
+		delete(wf.runningTasks, t)
+	} else {
+		ts = newTaskStatus(t)
+	}
+	ts.FinishTime = t.finishTime
+	ts.Skipped = t.skipped
+	wf.tasksCompleted++
+	wf.recentTasks.add(*ts)
+	if wf.TraceFile != "" {
+		wf.completedTasks = append(wf.completedTasks, *ts)
+	}
+	if HistoryFile != "" && !t.skipped {
+		for _, path := range ts.OutFiles {
+			wf.outputPaths = append(wf.outputPaths, path)
+		}
+	}
+
+	m := wf.procMetrics(t.Process.Name())
+	if t.skipped {
+		m.skipped++
+		return
+	}
+	m.finished++
+	m.observeDuration(t.finishTime.Sub(t.startTime).Seconds())
+	m.bytesWritten += outputBytes(t)
+}
+
+// recordTaskFailed records that the task t has failed, with the error message
+// msg. All failed tasks are kept, since a failing task normally stops the
+// run.
+func (wf *Workflow) recordTaskFailed(t *Task, msg interface{}) {
+	wf.tasksLock.Lock()
+	defer wf.tasksLock.Unlock()
+	ts, ok := wf.runningTasks[t]
+	if ok {
+		delete(wf.runningTasks, t)
+	} else {
+		ts = newTaskStatus(t)
+	}
+	ts.FinishTime = time.Now()
+	ts.Error = strings.TrimSpace(fmt.Sprintf("%v", msg))
+	wf.failedTasks = append(wf.failedTasks, *ts)
+	wf.procMetrics(t.Process.Name()).failed++
+}
+
+// taskStatusRing keeps the statusRecentTasks task statuses most recently added
+// to it
+type taskStatusRing struct {
+	tasks []TaskStatus
+	next  int
+}
+
+func (r *taskStatusRing) add(ts TaskStatus) {
+	if len(r.tasks) < statusRecentTasks {
+		r.tasks = append(r.tasks, ts)
+		return
+	}
+	r.tasks[r.next] = ts
+	r.next = (r.next + 1) % len(r.tasks)
+}
+
+// list returns the task statuses in the ring, oldest first
+func (r *taskStatusRing) list() []TaskStatus {
+	tasks := append([]TaskStatus{}, r.tasks[r.next:]...)
+	return append(tasks, r.tasks[:r.next]...)
+}
@@ -0,0 +1,86 @@
+package scipipe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestStatusHandler(t *testing.T) {
+	initTestLogs()
+
+	wf, _, _ := newFooBarTestWorkflow("TestStatusHandler_WF", t.TempDir(), "a", "b")
+	wf.Run()
+
+	server := httptest.NewServer(wf.StatusHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatalf("Could not get status: %v", err)
+	}
+	defer resp.Body.Close()
+	assertEqualValues(t, "application/json", resp.Header.Get("Content-Type"))
+
+	status := &WorkflowStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(status); err != nil {
+		t.Fatalf("Could not decode status: %v", err)
+	}
+
+	assertEqualValues(t, "TestStatusHandler_WF", status.Workflow)
+	assertEqualValues(t, 0, status.SlotsUsed)
+	assertEqualValues(t, 4, status.SlotsTotal)
+	assertEqualValues(t, 0, len(status.RunningTasks))
+	assertEqualValues(t, 4, len(status.CompletedTasks))
+	assertEqualValues(t, 4, status.TasksCompleted)
+	assertEqualValues(t, 0, len(status.FailedTasks))
+	assertEqualValues(t, 0, status.TasksFailed)
+	if status.StartTime.IsZero() {
+		t.Errorf("Start time of workflow not set")
+	}
+
+	procs := map[string]ProcessStatus{}
+	for _, ps := range status.Processes {
+		procs[ps.Name] = ps
+	}
+	barStatus, ok := procs["bar"]
+	if !ok {
+		t.Fatalf("Process bar not found in status: %v", status.Processes)
+	}
+	assertEqualValues(t, procStatusRun, barStatus.Status)
+	assertEqualValues(t, []string{"in"}, barStatus.InPorts)
+	assertEqualValues(t, 2, barStatus.Stats.TasksRun)
+
+	var fooToBar *ConnectionStatus
+	for i, cs := range status.Connections {
+		if cs.From == "foo.out" && cs.To == "bar.in" {
+			fooToBar = &status.Connections[i]
+		}
+	}
+	if fooToBar == nil {
+		t.Fatalf("Connection foo.out -> bar.in not found in status: %v", status.Connections)
+	}
+	assertEqualValues(t, 2, fooToBar.Sent)
+	assertEqualValues(t, false, fooToBar.Open)
+
+	for _, ts := range status.CompletedTasks {
+		if ts.Process == "bar" && ts.Command == "" {
+			t.Errorf("Command of completed task not set: %v", ts)
+		}
+	}
+}
+
+func TestTaskStatusRing(t *testing.T) {
+	r := taskStatusRing{}
+	assertEqualValues(t, []TaskStatus{}, r.list())
+
+	for i := 0; i < statusRecentTasks+10; i++ {
+		r.add(TaskStatus{Process: strconv.Itoa(i)})
+	}
+	tasks := r.list()
+	assertEqualValues(t, statusRecentTasks, len(tasks), "Wrong number of tasks kept")
+	assertEqualValues(t, "10", tasks[0].Process, "Oldest task kept should be the first one not pushed out")
+	assertEqualValues(t, strconv.Itoa(statusRecentTasks+9), tasks[len(tasks)-1].Process, "Newest task should be last")
+}
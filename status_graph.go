@@ -0,0 +1,177 @@
+package scipipe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Status graph
+// ----------------------------------------------------------------------------
+
+// Statuses of processes, as shown in the status graph
+const (
+	procStatusRun        = "run"
+	procStatusSkipped    = "skipped"
+	procStatusFailed     = "failed"
+	procStatusIncomplete = "incomplete"
+	procStatusNotReached = "not reached"
+)
+
+// Fill colors of processes with the different statuses in the status graph
+var procStatusColors = map[string]string{
+	procStatusRun:        "#C7E9C0",
+	procStatusSkipped:    "#D9D9D9",
+	procStatusFailed:     "#FCAE91",
+	procStatusIncomplete: "#FFF3B0",
+	procStatusNotReached: "#FFFFFF",
+}
+
+// StatusDotGraph generates a graph description in DOT format, like DotGraph,
+// but with processes coloured by the outcome of their tasks, when the
+// workflow has been run (or while it is running): Green when all tasks were
+// run (or some skipped), grey when all tasks were skipped because their
+// outputs already existed, red when any task failed, yellow when tasks are
+// still unfinished, and white (with a dashed border) when the process was
+// never reached. Processes are annotated with the number of tasks and their
+// total runtime, and edges with the number of IPs (or parameters) that passed
+// through them.
+//
+// Processes not created with NewProc, such as the ones in the components
+// library, are coloured as run if they have sent anything on any of their
+// out-ports, and as not reached otherwise.
+func (wf *Workflow) StatusDotGraph() (dot string) {
+	dot = fmt.Sprintf(`digraph "%s" {`+"\n", wf.Name())
+	dot += `  rankdir=LR;` + "\n"
+	dot += `  graph [fontname="Arial",fontsize=13,color="#384A52",fontcolor="#384A52"];` + "\n"
+	dot += `  node  [fontname="Arial",fontsize=11,color="#384A52",fontcolor="#384A52",fillcolor="#EFF2F5",shape=box,style=filled];` + "\n"
+	dot += `  edge  [fontname="Arial",fontsize=9, color="#384A52",fontcolor="#384A52"];` + "\n"
+
+	edges := wf.statusEdges()
+	sentByProc := map[string]int{}
+	for _, e := range edges {
+		sentByProc[e.fromProc] += e.sentCount()
+	}
+	for _, p := range wf.ProcsSorted() {
+		dot += wf.statusDotNodes(p, "  ", sentByProc)
+	}
+	for _, e := range edges {
+		attrs := fmt.Sprintf(`label="%d %s"`, e.sentCount(), map[bool]string{false: "IPs", true: "params"}[e.isParam])
+		if e.isParam {
+			attrs += `, style="dashed"`
+		}
+		if wf.PlotConf.EdgeLabels {
+			attrs += fmt.Sprintf(`, taillabel="%s", headlabel="%s"`, e.fromPort, e.toPort)
+		}
+		dot += fmt.Sprintf(`  "%s" -> "%s" [%s];`+"\n", e.fromProc, e.toProc, attrs)
+	}
+	dot += "}\n"
+	return dot
+}
+
+// PlotStatusGraph writes the status graph (See StatusDotGraph) to a DOT file
+func (wf *Workflow) PlotStatusGraph(filePath string) {
+	createDirs(filePath)
+	err := ioutil.WriteFile(filePath, []byte(wf.StatusDotGraph()), 0644)
+	if err != nil {
+		wf.Failf("Could not write to DOT-file %s: %s", filePath, err)
+	}
+}
+
+// statusDotNodes returns the DOT node definition for the process p, coloured
+// by its status, or a cluster with the inner processes, if p is a
+// sub-workflow
+func (wf *Workflow) statusDotNodes(p WorkflowProcess, indent string, sentByProc map[string]int) (dot string) {
+	if sw, ok := p.(*SubWorkflow); ok {
+		dot = fmt.Sprintf(`%ssubgraph "cluster_%s" {`+"\n", indent, sw.Name())
+		dot += fmt.Sprintf(`%s  label="%s";`+"\n", indent, sw.Name())
+		for _, inner := range sw.ProcsSorted() {
+			dot += wf.statusDotNodes(inner, indent+"  ", sentByProc)
+		}
+		dot += indent + "}\n"
+		return dot
+	}
+
+	label := p.Name()
+	status := procStatusNotReached
+	if sentByProc[p.Name()] > 0 {
+		status = procStatusRun
+	}
+	if proc, ok := p.(*Process); ok {
+		stats := proc.Stats()
+		status = stats.status()
+		label += fmt.Sprintf(`\n%d run, %d skipped`, stats.TasksRun, stats.TasksSkipped)
+		if stats.TasksFailed > 0 {
+			label += fmt.Sprintf(`, %d failed`, stats.TasksFailed)
+		}
+		if unfinished := stats.TasksCreated - stats.TasksRun - stats.TasksSkipped - stats.TasksFailed; unfinished > 0 {
+			label += fmt.Sprintf(`, %d unfinished`, unfinished)
+		}
+		label += `\n` + stats.Runtime.Round(time.Millisecond).String()
+	}
+
+	style := "filled"
+	if status == procStatusNotReached {
+		style = "filled,dashed"
+	}
+	return fmt.Sprintf(`%s"%s" [shape=box, label="%s", fillcolor="%s", style="%s", tooltip="%s"];`+"\n", indent, p.Name(), label, procStatusColors[status], style, status)
+}
+
+// status returns the status of a process, based on its statistics
+func (s ProcessStats) status() string {
+	switch {
+	case s.TasksFailed > 0:
+		return procStatusFailed
+	case !s.Started || s.TasksCreated == 0:
+		return procStatusNotReached
+	case s.TasksRun+s.TasksSkipped < s.TasksCreated || !s.Finished:
+		return procStatusIncomplete
+	case s.TasksRun == 0:
+		return procStatusSkipped
+	default:
+		return procStatusRun
+	}
+}
+
+// snapshotRunEdges keeps a copy of the connections of the workflow, so that
+// the status graph can be drawn also after the connections have been removed
+// when closing the ports at the end of the run
+func (wf *Workflow) snapshotRunEdges() {
+	edges := []graphEdge{}
+	for _, p := range wf.ProcsSorted() {
+		edges = append(edges, graphEdges(p)...)
+	}
+	wf.runEdgesLock.Lock()
+	wf.runEdges = edges
+	wf.runEdgesLock.Unlock()
+}
+
+// statusEdges returns the edges to draw in the status graph, which is the
+// snapshot taken when starting the run, or the current connections if the
+// workflow has not been run
+func (wf *Workflow) statusEdges() []graphEdge {
+	wf.runEdgesLock.Lock()
+	defer wf.runEdgesLock.Unlock()
+	if wf.runEdges != nil {
+		return wf.runEdges
+	}
+	edges := []graphEdge{}
+	for _, p := range wf.ProcsSorted() {
+		edges = append(edges, graphEdges(p)...)
+	}
+	return edges
+}
+
+// writeStatusGraph writes the status graph to the file configured in
+// PlotConf.StatusGraphFile, if any
+func (wf *Workflow) writeStatusGraph() {
+	if wf.PlotConf.StatusGraphFile == "" {
+		return
+	}
+	createDirs(wf.PlotConf.StatusGraphFile)
+	err := ioutil.WriteFile(wf.PlotConf.StatusGraphFile, []byte(wf.StatusDotGraph()), 0644)
+	if err != nil {
+		Error.Printf("[Workflow:%s] Could not write status graph to %s: %v\n", wf.Name(), wf.PlotConf.StatusGraphFile, err)
+	}
+}
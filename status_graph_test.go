@@ -0,0 +1,115 @@
+package scipipe
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStatusDotGraph(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	// Before running, nothing is reached
+	wf, _, _ := newFooBarTestWorkflow("TestStatusDotGraph", dir, "foo", "bar")
+	dot := wf.StatusDotGraph()
+	assertDotNode(t, dot, "foo", `foo\n0 run, 0 skipped\n0s`, procStatusNotReached)
+	assertDotNode(t, dot, "bar", `bar\n0 run, 0 skipped\n0s`, procStatusNotReached)
+
+	// Run only the first process, with the status graph written to file
+	wf, _, _ = newFooBarTestWorkflow("TestStatusDotGraph", dir, "foo", "bar")
+	wf.PlotConf.StatusGraphFile = filepath.Join(dir, "status_graph.dot")
+	wf.RunTo("foo")
+	dot = wf.StatusDotGraph()
+	assertDotNode(t, dot, "foo", `foo\n2 run, 0 skipped`, procStatusRun)
+	assertDotNode(t, dot, "bar", `bar\n0 run, 0 skipped`, procStatusNotReached)
+	if strings.Contains(dot, `"foo" -> "bar"`) {
+		t.Errorf("Expected no edge to process not part of the run in status graph:\n%s", dot)
+	}
+	dat, err := ioutil.ReadFile(wf.PlotConf.StatusGraphFile)
+	if err != nil {
+		t.Fatalf("Status graph file was not written: %v", err)
+	}
+	assertEqualValues(t, dot, string(dat))
+
+	// Run the whole workflow, where the first process will now be skipped
+	wf, _, _ = newFooBarTestWorkflow("TestStatusDotGraph", dir, "foo", "bar")
+	wf.Run()
+	dot = wf.StatusDotGraph()
+	assertDotNode(t, dot, "foo", `foo\n0 run, 2 skipped\n0s`, procStatusSkipped)
+	assertDotNode(t, dot, "bar", `bar\n2 run, 0 skipped`, procStatusRun)
+	if !strings.Contains(dot, `"foo" -> "bar" [label="2 IPs", taillabel="out", headlabel="in"];`) {
+		t.Errorf("Expected edge with two IPs in status graph:\n%s", dot)
+	}
+}
+
+func TestStatusDotGraphRunToMiddleProcess(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	wf, _, bar := newFooBarTestWorkflow("TestStatusDotGraphRunToMiddleProcess", dir, "foo", "bar")
+	baz := wf.NewProc("baz", "cat {i:in} > {o:out}")
+	baz.In("in").From(bar.Out("out"))
+	baz.SetOut("out", "{i:in}.baz.txt")
+	wf.RunTo("bar")
+
+	dot := wf.StatusDotGraph()
+	assertDotNode(t, dot, "bar", `bar\n2 run, 0 skipped`, procStatusRun)
+	assertDotNode(t, dot, "baz", `baz\n0 run, 0 skipped`, procStatusNotReached)
+	if !strings.Contains(dot, `"foo" -> "bar" [label="2 IPs", taillabel="out", headlabel="in"];`) {
+		t.Errorf("Expected edge with two IPs in status graph:\n%s", dot)
+	}
+	if strings.Contains(dot, `-> "baz"`) {
+		t.Errorf("Expected no edge to process not part of the run in status graph:\n%s", dot)
+	}
+	if strings.Contains(dot, `-> "sink"`) {
+		t.Errorf("Expected no edge to the sink in status graph:\n%s", dot)
+	}
+}
+
+func TestStatusGraphWrittenOnFailure(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	wf, _, _ := newFooBarTestWorkflow("TestStatusGraphWrittenOnFailure", dir, "foo")
+	wf.PlotConf.StatusGraphFile = filepath.Join(dir, "status_graph.dot")
+	// recordFailedRuns is what Fail calls before exiting, so call it as if
+	// the workflow was running when the failure happened
+	addRunningWorkflow(wf)
+	recordFailedRuns()
+	removeRunningWorkflow(wf)
+
+	dat, err := ioutil.ReadFile(wf.PlotConf.StatusGraphFile)
+	if err != nil {
+		t.Fatalf("Status graph file was not written: %v", err)
+	}
+	assertEqualValues(t, wf.StatusDotGraph(), string(dat))
+}
+
+func TestProcessStatsStatus(t *testing.T) {
+	for expected, stats := range map[string]ProcessStats{
+		procStatusNotReached: {},
+		procStatusRun:        {Started: true, Finished: true, TasksCreated: 3, TasksRun: 2, TasksSkipped: 1},
+		procStatusSkipped:    {Started: true, Finished: true, TasksCreated: 3, TasksSkipped: 3},
+		procStatusFailed:     {Started: true, TasksCreated: 3, TasksRun: 1, TasksFailed: 1},
+		procStatusIncomplete: {Started: true, TasksCreated: 3, TasksRun: 1},
+	} {
+		assertEqualValues(t, expected, stats.status())
+	}
+}
+
+func assertDotNode(t *testing.T, dot string, procName string, labelPrefix string, status string) {
+	ptn := regexp.MustCompile(`"` + procName + `" \[shape=box, label="([^"]*)", fillcolor="([^"]*)", style="[^"]*", tooltip="([^"]*)"\];`)
+	m := ptn.FindStringSubmatch(dot)
+	if m == nil {
+		t.Errorf("Node for process %s not found in status graph:\n%s", procName, dot)
+		return
+	}
+	if !strings.HasPrefix(m[1], labelPrefix) {
+		t.Errorf("Expected label of process %s to start with '%s', but was '%s'", procName, labelPrefix, m[1])
+	}
+	assertEqualValues(t, procStatusColors[status], m[2])
+	assertEqualValues(t, status, m[3])
+}
@@ -0,0 +1,179 @@
+package scipipe
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Structured (JSON) logging
+// ----------------------------------------------------------------------------
+
+// LogFormat is the format of the log output
+type LogFormat int
+
+const (
+	// LogFormatText is the default, plain text log format, with lines like
+	// "AUDIT   2006/01/02 15:04:05 [Task:name] Executing: ..."
+	LogFormatText LogFormat = iota
+	// LogFormatJSON logs one JSON object per line (JSON lines), with the
+	// fields in LogEntry
+	LogFormatJSON
+)
+
+// logFormat is the current log format, set with SetLogFormat, or with the
+// SCIPIPE_LOG_FORMAT environment variable
+var (
+	logFormat     = LogFormatText
+	logFormatLock sync.Mutex
+)
+
+// LogEntry is a log message in the JSON log format. Besides the level, time
+// and message, which are set for all entries, the other fields are set for
+// the events they apply to, such as workflow_started, workflow_finished,
+// task_started, task_finished, task_skipped and task_failed.
+type LogEntry struct {
+	Time     time.Time `json:"time"`
+	Level    string    `json:"level"`
+	Event    string    `json:"event,omitempty"`
+	Workflow string    `json:"workflow,omitempty"`
+	Process  string    `json:"process,omitempty"`
+	// Task is the temp dir of the task, which identifies the task
+	Task      string  `json:"task,omitempty"`
+	Command   string  `json:"command,omitempty"`
+	DurationS float64 `json:"duration_s,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Msg       string  `json:"msg"`
+}
+
+// SetLogFormat sets the format of the log output, for the loggers Trace,
+// Debug, Info, Audit, Warning and Error. The format can also be set to JSON by
+// setting the environment variable SCIPIPE_LOG_FORMAT to "json".
+func SetLogFormat(format LogFormat) {
+	logFormatLock.Lock()
+	logFormat = format
+	logFormatLock.Unlock()
+	applyLogFormat()
+}
+
+func getLogFormat() LogFormat {
+	logFormatLock.Lock()
+	defer logFormatLock.Unlock()
+	return logFormat
+}
+
+// initLogFormat sets the log format from the SCIPIPE_LOG_FORMAT environment
+// variable, if set, and applies it to the loggers
+func initLogFormat() {
+	if formatStr, envSet := os.LookupEnv("SCIPIPE_LOG_FORMAT"); envSet {
+		switch strings.ToLower(formatStr) {
+		case "json":
+			SetLogFormat(LogFormatJSON)
+			return
+		case "text", "":
+			SetLogFormat(LogFormatText)
+			return
+		default:
+			Failf("Unknown value of SCIPIPE_LOG_FORMAT (should be text or json): %s\n", formatStr)
+		}
+	}
+	applyLogFormat()
+}
+
+// applyLogFormat makes the loggers write in the current log format. For the
+// JSON format, the output of each logger is wrapped in a jsonLogWriter, and
+// for the text format, any such wrapping is removed.
+func applyLogFormat() {
+	format := getLogFormat()
+	for _, lg := range []*log.Logger{Trace, Debug, Info, Audit, Warning, Error} {
+		if lg == nil {
+			continue
+		}
+		jw, isJSON := lg.Writer().(*jsonLogWriter)
+		switch {
+		case format == LogFormatJSON && !isJSON:
+			lg.SetOutput(&jsonLogWriter{
+				out:    lg.Writer(),
+				level:  strings.ToLower(strings.TrimSpace(lg.Prefix())),
+				prefix: lg.Prefix(),
+				flags:  lg.Flags(),
+			})
+			lg.SetPrefix("")
+			lg.SetFlags(0)
+		case format == LogFormatText && isJSON:
+			lg.SetOutput(jw.out)
+			lg.SetPrefix(jw.prefix)
+			lg.SetFlags(jw.flags)
+		}
+	}
+}
+
+// logPrefixPtrn matches the prefixes of log messages, such as [Task:name],
+// that tell what part of the workflow the message is about
+var logPrefixPtrn = regexp.MustCompile(`^\[(Workflow|SubWorkflow|Process|Task):([^\]]*)\] ?`)
+
+// jsonLogWriter writes log messages as JSON lines to out. Messages that are
+// already a LogEntry in JSON (See logEvent) get their time and level set,
+// while plain text messages are wrapped in a LogEntry, with the workflow or
+// process taken from the message prefix, if any.
+type jsonLogWriter struct {
+	out   io.Writer
+	level string
+	// prefix and flags are the ones of the logger before it was set to log
+	// JSON, so that they can be restored
+	prefix string
+	flags  int
+}
+
+func (w *jsonLogWriter) Write(p []byte) (n int, err error) {
+	msg := strings.TrimRight(string(p), "\n")
+	entry := LogEntry{}
+	if !(strings.HasPrefix(msg, "{") && json.Unmarshal([]byte(msg), &entry) == nil) {
+		entry = LogEntry{Msg: msg}
+		if m := logPrefixPtrn.FindStringSubmatch(msg); m != nil {
+			if m[1] == "Workflow" || m[1] == "SubWorkflow" {
+				entry.Workflow = m[2]
+			} else {
+				entry.Process = m[2]
+			}
+			entry.Msg = strings.TrimRight(msg[len(m[0]):], "\n")
+		}
+	}
+	entry.Time = time.Now()
+	entry.Level = w.level
+
+	buf := &bytes.Buffer{}
+	err = json.NewEncoder(buf).Encode(entry)
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.out.Write(buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logEvent logs an event to logger. With the text log format, textMsg is
+// logged as is, while with the JSON format, entry is logged, with the message
+// taken from textMsg, without any prefix like [Task:name].
+func logEvent(logger *log.Logger, entry LogEntry, textMsg string) {
+	if getLogFormat() != LogFormatJSON {
+		logger.Print(textMsg)
+		return
+	}
+	entry.Msg = strings.TrimRight(logPrefixPtrn.ReplaceAllString(textMsg, ""), "\n")
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		logger.Print(textMsg)
+		return
+	}
+	logger.Print(string(entryJSON))
+}
@@ -0,0 +1,117 @@
+package scipipe
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogFormat(t *testing.T) {
+	initTestLogs()
+
+	logBuf := &bytes.Buffer{}
+	origWriter := Audit.Writer()
+	Audit.SetOutput(logBuf)
+	SetLogFormat(LogFormatJSON)
+	defer func() {
+		SetLogFormat(LogFormatText)
+		Audit.SetOutput(origWriter)
+	}()
+
+	wf := NewWorkflow("TestJSONLogFormat_WF", 4)
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	foo.SetOut("out", ".tmp/json_log_foo.txt")
+	wf.Run()
+
+	events := map[string]LogEntry{}
+	scanner := bufio.NewScanner(logBuf)
+	for scanner.Scan() {
+		entry := LogEntry{}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatalf("Log line is not valid JSON: %s", scanner.Text())
+		}
+		assertEqualValues(t, "audit", entry.Level)
+		if entry.Time.IsZero() {
+			t.Errorf("Time not set for log entry: %s", scanner.Text())
+		}
+		if entry.Event != "" {
+			events[entry.Event] = entry
+		}
+	}
+
+	for _, event := range []string{"workflow_started", "task_started", "task_finished", "workflow_finished"} {
+		entry, ok := events[event]
+		if !ok {
+			t.Errorf("No log entry found for event %s", event)
+			continue
+		}
+		assertEqualValues(t, "TestJSONLogFormat_WF", entry.Workflow)
+	}
+	finished := events["task_finished"]
+	assertEqualValues(t, "foo", finished.Process)
+	assertEqualValues(t, "echo foo > .tmp/json_log_foo.txt", finished.Command)
+	assertEqualValues(t, "Finished: echo foo > .tmp/json_log_foo.txt", finished.Msg)
+	if finished.Task == "" {
+		t.Errorf("Task temp dir not set for task_finished event")
+	}
+	if finished.DurationS <= 0 {
+		t.Errorf("Duration not set for task_finished event: %f", finished.DurationS)
+	}
+
+	cleanFilePatterns(".tmp/json_log_*")
+}
+
+func TestJSONLogFormatTaskFailed(t *testing.T) {
+	// The failing task exits the program, so run the workflow in a
+	// sub-process of the test (See ensureFailsProgram), in a temp dir, since
+	// the temp folder of the failed task is left behind
+	if os.Getenv("BE_CRASHER") == "1" {
+		initTestLogs()
+		SetLogFormat(LogFormatJSON)
+		Check(os.Chdir(os.Getenv("TEST_OUT_DIR")))
+		wf := NewWorkflow("TestJSONLogFormatTaskFailed_WF", 4)
+		foo := wf.NewProc("foo", "exit 1 # {o:out}")
+		foo.SetOut("out", "foo.txt")
+		wf.Run()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=TestJSONLogFormatTaskFailed")
+	cmd.Env = append(os.Environ(), "BE_CRASHER=1", "TEST_OUT_DIR="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+	if e, ok := err.(*exec.ExitError); !ok || e.Success() {
+		t.Fatalf("Process ran with err %v, want exit status 1", err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		entry := LogEntry{}
+		if json.Unmarshal([]byte(line), &entry) != nil || entry.Event != "task_failed" {
+			continue
+		}
+		assertEqualValues(t, "error", entry.Level)
+		assertEqualValues(t, "TestJSONLogFormatTaskFailed_WF", entry.Workflow)
+		assertEqualValues(t, "foo", entry.Process)
+		if entry.Error == "" {
+			t.Errorf("Error not set for task_failed event: %s", line)
+		}
+		return
+	}
+	t.Errorf("No log entry found for event task_failed in output:\n%s", out)
+}
+
+func TestJSONLogWriterPlainMessage(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := &jsonLogWriter{out: buf, level: "warning"}
+	_, err := w.Write([]byte("[Process:foo] Something happened\n"))
+	assertNil(t, err)
+
+	entry := LogEntry{}
+	assertNil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assertEqualValues(t, "warning", entry.Level)
+	assertEqualValues(t, "foo", entry.Process)
+	assertEqualValues(t, "Something happened", entry.Msg)
+}
@@ -0,0 +1,291 @@
+package scipipe
+
+import (
+	"sync"
+)
+
+// ----------------------------------------------------------------------------
+// SubWorkflow
+// ----------------------------------------------------------------------------
+
+// SubWorkflow is a process that contains its own set of processes, and which
+// exposes selected ports of those processes as its own in-, out- and param
+// ports. It can thus be used as any other process in a workflow, while
+// encapsulating a whole network of processes. A good way to make a
+// sub-workflow reusable across workflows, is to create it in a function that
+// takes the workflow and a name as arguments, just like the factory functions
+// of other processes.
+type SubWorkflow struct {
+	BaseProcess
+	procs           map[string]WorkflowProcess
+	sink            *Sink
+	inBridges       map[string]*OutPort
+	outBridges      map[string]*InPort
+	inParamBridges  map[string]*OutParamPort
+	outParamBridges map[string]*InParamPort
+}
+
+// NewSubWorkflow returns a new SubWorkflow, added to the workflow wf
+func NewSubWorkflow(wf *Workflow, name string) *SubWorkflow {
+	sw := &SubWorkflow{
+		BaseProcess:     NewBaseProcess(wf, name),
+		procs:           map[string]WorkflowProcess{},
+		sink:            NewSink(wf, name+"_sink"),
+		inBridges:       map[string]*OutPort{},
+		outBridges:      map[string]*InPort{},
+		inParamBridges:  map[string]*OutParamPort{},
+		outParamBridges: map[string]*InParamPort{},
+	}
+	wf.AddProc(sw)
+	return sw
+}
+
+// ----------------------------------------------------------------------------
+// Process management
+// ----------------------------------------------------------------------------
+
+// NewProc returns a new process based on a commandPattern (See the
+// documentation for scipipe.NewProc for more details about the pattern), and
+// adds it to the sub-workflow, rather than to the workflow itself
+func (sw *SubWorkflow) NewProc(procName string, commandPattern string) *Process {
+	proc := newProc(sw.workflow, procName, commandPattern)
+	sw.AddProc(proc)
+	return proc
+}
+
+// AddProc adds a process to the sub-workflow. Since processes such as the ones
+// in the components library add themselves to the workflow they are created
+// with, proc is removed from the (parent) workflow, if it was added there.
+func (sw *SubWorkflow) AddProc(proc WorkflowProcess) {
+	if sw.procs[proc.Name()] != nil {
+		sw.Failf("A process with name (%s) already exists in the sub-workflow! Use a more unique name!", proc.Name())
+	}
+	if sw.workflow.procs[proc.Name()] == proc {
+		delete(sw.workflow.procs, proc.Name())
+	}
+	sw.procs[proc.Name()] = proc
+}
+
+// Proc returns the process with name procName from the sub-workflow
+func (sw *SubWorkflow) Proc(procName string) WorkflowProcess {
+	if _, ok := sw.procs[procName]; !ok {
+		sw.Failf("No process named (%s) in sub-workflow", procName)
+	}
+	return sw.procs[procName]
+}
+
+// Procs returns a map of all processes keyed by their names in the
+// sub-workflow
+func (sw *SubWorkflow) Procs() map[string]WorkflowProcess {
+	return sw.procs
+}
+
+// ProcsSorted returns the processes of the sub-workflow, in an array, sorted
+// by the process names
+func (sw *SubWorkflow) ProcsSorted() []WorkflowProcess {
+	return sortedWFMapValues(sw.procs)
+}
+
+// ----------------------------------------------------------------------------
+// Exposing inner ports
+// ----------------------------------------------------------------------------
+
+// ExposeIn exposes the in-port ipt of one of the inner processes as an in-port
+// named portName of the sub-workflow
+func (sw *SubWorkflow) ExposeIn(portName string, ipt *InPort) {
+	sw.InitInPort(sw, portName)
+	bridge := NewOutPort(portName)
+	bridge.process = sw
+	bridge.To(ipt)
+	sw.inBridges[portName] = bridge
+}
+
+// ExposeOut exposes the out-port opt of one of the inner processes as an
+// out-port named portName of the sub-workflow
+func (sw *SubWorkflow) ExposeOut(portName string, opt *OutPort) {
+	sw.InitOutPort(sw, portName)
+	bridge := NewInPort(portName)
+	bridge.process = sw
+	bridge.From(opt)
+	sw.outBridges[portName] = bridge
+}
+
+// ExposeInParam exposes the param in-port pip of one of the inner processes as
+// a param in-port named portName of the sub-workflow
+func (sw *SubWorkflow) ExposeInParam(portName string, pip *InParamPort) {
+	sw.InitInParamPort(sw, portName)
+	bridge := NewOutParamPort(portName)
+	bridge.process = sw
+	bridge.To(pip)
+	sw.inParamBridges[portName] = bridge
+}
+
+// ExposeOutParam exposes the param out-port pop of one of the inner processes
+// as a param out-port named portName of the sub-workflow
+func (sw *SubWorkflow) ExposeOutParam(portName string, pop *OutParamPort) {
+	sw.InitOutParamPort(sw, portName)
+	bridge := NewInParamPort(portName)
+	bridge.process = sw
+	bridge.From(pop)
+	sw.outParamBridges[portName] = bridge
+}
+
+// In returns the (exposed) in-port with name portName
+func (sw *SubWorkflow) In(portName string) *InPort { return sw.InPort(portName) }
+
+// Out returns the (exposed) out-port with name portName
+func (sw *SubWorkflow) Out(portName string) *OutPort { return sw.OutPort(portName) }
+
+// InParam returns the (exposed) param in-port with name portName
+func (sw *SubWorkflow) InParam(portName string) *InParamPort { return sw.InParamPort(portName) }
+
+// OutParam returns the (exposed) param out-port with name portName
+func (sw *SubWorkflow) OutParam(portName string) *OutParamPort { return sw.OutParamPort(portName) }
+
+// ----------------------------------------------------------------------------
+// Run method
+// ----------------------------------------------------------------------------
+
+// Run runs all the inner processes of the sub-workflow, and forwards IPs and
+// parameters between the exposed ports and the inner ports they expose. It
+// returns when all inner processes are done.
+func (sw *SubWorkflow) Run() {
+	wg := &sync.WaitGroup{}
+	runInGoRoutine := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+
+	for portName, bridge := range sw.inBridges {
+		inPort, bridge := sw.InPort(portName), bridge
+		runInGoRoutine(func() {
+			defer bridge.Close()
+			for ip := range inPort.Chan {
+				bridge.Send(ip)
+			}
+		})
+	}
+	for portName, bridge := range sw.inParamBridges {
+		inParamPort, bridge := sw.InParamPort(portName), bridge
+		runInGoRoutine(func() {
+			defer bridge.Close()
+			for param := range inParamPort.Chan {
+				bridge.Send(param)
+			}
+		})
+	}
+	for portName, bridge := range sw.outBridges {
+		outPort, bridge := sw.OutPort(portName), bridge
+		runInGoRoutine(func() {
+			defer outPort.Close()
+			for ip := range bridge.Chan {
+				outPort.Send(ip)
+			}
+		})
+	}
+	for portName, bridge := range sw.outParamBridges {
+		outParamPort, bridge := sw.OutParamPort(portName), bridge
+		runInGoRoutine(func() {
+			defer outParamPort.Close()
+			for param := range bridge.Chan {
+				outParamPort.Send(param)
+			}
+		})
+	}
+
+	for _, proc := range sw.procs {
+		Debug.Printf("[SubWorkflow:%s] Starting process (%s) in new go-routine", sw.Name(), proc.Name())
+		proc := proc
+		runInGoRoutine(func() { sw.workflow.runProc(proc) })
+	}
+	runInGoRoutine(sw.sink.Run)
+
+	wg.Wait()
+}
+
+// ----------------------------------------------------------------------------
+// Helper methods
+// ----------------------------------------------------------------------------
+
+// connectDanglingOutPorts connects any unconnected out-ports of the inner
+// processes to the sink of the sub-workflow, to make sure they are executed
+func (sw *SubWorkflow) connectDanglingOutPorts() {
+	for _, proc := range sw.ProcsSorted() {
+		if inner, ok := proc.(*SubWorkflow); ok {
+			inner.connectDanglingOutPorts()
+		}
+		for _, opt := range proc.OutPorts() {
+			if !opt.Ready() {
+				Debug.Printf("Connecting disconnected out-port (%s) of process (%s) to sub-workflow sink", opt.Name(), proc.Name())
+				sw.sink.From(opt)
+			}
+		}
+		for _, pop := range proc.OutParamPorts() {
+			if !pop.Ready() {
+				Debug.Printf("Connecting disconnected param out-port (%s) of process (%s) to sub-workflow sink", pop.Name(), proc.Name())
+				sw.sink.FromParam(pop)
+			}
+		}
+	}
+}
+
+// validate returns errors for problems with the inner processes of the
+// sub-workflow. See Workflow.Validate for the checks done.
+func (sw *SubWorkflow) validate() []error {
+	errs := []error{}
+	procs := sw.ProcsSorted()
+	for _, proc := range procs {
+		errs = append(errs, validateProc(proc)...)
+	}
+	errs = append(errs, findCycles(procs)...)
+	return errs
+}
+
+// resolveInPorts follows connections through exposed ports of sub-workflows,
+// and returns the in-ports of the processes that IPs sent to ipt will
+// eventually arrive at
+func resolveInPorts(ipt *InPort) []*InPort {
+	sw, ok := ipt.process.(*SubWorkflow)
+	if !ok {
+		return []*InPort{ipt}
+	}
+	var nextPorts map[string]*InPort
+	if bridge, ok := sw.inBridges[ipt.name]; ok && sw.inPorts[ipt.name] == ipt {
+		nextPorts = bridge.RemotePorts
+	} else if sw.outBridges[ipt.name] == ipt {
+		nextPorts = sw.OutPort(ipt.name).RemotePorts
+	} else {
+		return []*InPort{ipt}
+	}
+	resolved := []*InPort{}
+	for _, name := range sortedInPortMapKeys(nextPorts) {
+		resolved = append(resolved, resolveInPorts(nextPorts[name])...)
+	}
+	return resolved
+}
+
+// resolveInParamPorts follows connections through exposed param ports of
+// sub-workflows, and returns the param in-ports of the processes that
+// parameters sent to pip will eventually arrive at
+func resolveInParamPorts(pip *InParamPort) []*InParamPort {
+	sw, ok := pip.process.(*SubWorkflow)
+	if !ok {
+		return []*InParamPort{pip}
+	}
+	var nextPorts map[string]*InParamPort
+	if bridge, ok := sw.inParamBridges[pip.name]; ok && sw.inParamPorts[pip.name] == pip {
+		nextPorts = bridge.RemotePorts
+	} else if sw.outParamBridges[pip.name] == pip {
+		nextPorts = sw.OutParamPort(pip.name).RemotePorts
+	} else {
+		return []*InParamPort{pip}
+	}
+	resolved := []*InParamPort{}
+	for _, name := range sortedInParamPortMapKeys(nextPorts) {
+		resolved = append(resolved, resolveInParamPorts(nextPorts[name])...)
+	}
+	return resolved
+}
@@ -0,0 +1,94 @@
+package scipipe
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestSubWorkflow(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestSubWorkflow", 4)
+
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	foo.SetOut("out", ".tmp/subwf_foo.txt")
+
+	sw := newFooToBazSubWorkflow(wf, "foo2baz")
+	sw.In("in").From(foo.Out("out"))
+
+	cat := wf.NewProc("cat", "cat {i:in} > {o:out}")
+	cat.SetOut("out", "{i:in}.cat.txt")
+	cat.In("in").From(sw.Out("out"))
+
+	wf.Run()
+
+	outFile := ".tmp/subwf_foo.txt.bar.txt.baz.txt.cat.txt"
+	dat, err := ioutil.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Could not read output file of sub-workflow: %s", outFile)
+	}
+	assertEqualValues(t, "baz\n", string(dat))
+
+	cleanFiles(".tmp/subwf_foo.txt", ".tmp/subwf_foo.txt.bar.txt", ".tmp/subwf_foo.txt.bar.txt.baz.txt", outFile)
+}
+
+func TestSubWorkflowDotGraph(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("testwf", 4)
+
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	sw := newFooToBazSubWorkflow(wf, "foo2baz")
+	sw.In("in").From(foo.Out("out"))
+	cat := wf.NewProc("cat", "cat {i:in} > {o:out}")
+	cat.In("in").From(sw.Out("out"))
+
+	expected := `digraph "testwf" {
+  rankdir=LR;
+  graph [fontname="Arial",fontsize=13,color="#384A52",fontcolor="#384A52"];
+  node  [fontname="Arial",fontsize=11,color="#384A52",fontcolor="#384A52",fillcolor="#EFF2F5",shape=box,style=filled];
+  edge  [fontname="Arial",fontsize=9, color="#384A52",fontcolor="#384A52"];
+  "cat" [shape=box];
+  "foo" [shape=box];
+  subgraph "cluster_foo2baz" {
+    label="foo2baz";
+    "foo2baz_b2z" [shape=box];
+    "foo2baz_f2b" [shape=box];
+  }
+  "foo" -> "foo2baz_f2b" [taillabel="out", headlabel="in"];
+  "foo2baz_b2z" -> "cat" [taillabel="out", headlabel="in"];
+  "foo2baz_f2b" -> "foo2baz_b2z" [taillabel="out", headlabel="in"];
+}
+`
+	actual := wf.DotGraph()
+	if actual != expected {
+		t.Errorf("Dot graph with sub-workflow is not as expected!\nEXPECTED:\n%s\nACTUAL:\n%s\n", expected, actual)
+	}
+}
+
+func TestSubWorkflowValidate(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestSubWorkflowValidate", 4)
+
+	sw := NewSubWorkflow(wf, "subwf")
+	sw.NewProc("cat", "cat {i:in} > {o:out}")
+
+	assertErrorsContain(t, wf.Validate(),
+		"[Process:cat] In-port (in) is not connected",
+	)
+}
+
+// newFooToBazSubWorkflow returns a sub-workflow that replaces foo with bar,
+// and then bar with baz, in two separate inner processes
+func newFooToBazSubWorkflow(wf *Workflow, name string) *SubWorkflow {
+	sw := NewSubWorkflow(wf, name)
+
+	f2b := sw.NewProc(name+"_f2b", "sed 's/foo/bar/g' {i:in} > {o:out}")
+	f2b.SetOut("out", "{i:in}.bar.txt")
+
+	b2z := sw.NewProc(name+"_b2z", "sed 's/bar/baz/g' {i:in} > {o:out}")
+	b2z.SetOut("out", "{i:in}.baz.txt")
+	b2z.In("in").From(f2b.Out("out"))
+
+	sw.ExposeIn("in", f2b.In("in"))
+	sw.ExposeOut("out", b2z.Out("out"))
+	return sw
+}
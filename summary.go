@@ -0,0 +1,215 @@
+package scipipe
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Run summary
+// ----------------------------------------------------------------------------
+
+// RunSummary is a summary of a run of a workflow, with the number of tasks
+// run, skipped and failed per process, the CPU time used compared to the wall
+// clock time, and the critical path through the tasks run
+type RunSummary struct {
+	Workflow  string
+	Processes []ProcessSummary
+	// WallTime is the time since the workflow started running
+	WallTime time.Duration
+	// CPUTime is the sum of the execution times of all tasks, each multiplied
+	// by the number of cores used by the task (See Process.CoresPerTask)
+	CPUTime time.Duration
+	// CriticalPath is the longest chain of tasks, in terms of execution
+	// time, where each task used outputs of the previous one, starting with
+	// the most upstream task. Only tasks run (not skipped) are included. The
+	// tasks needed are only kept in memory if Workflow.PrintSummary is set.
+	CriticalPath []*AuditInfo
+	// CriticalPathTime is the sum of the execution times of the tasks on the
+	// critical path
+	CriticalPathTime time.Duration
+}
+
+// ProcessSummary contains the number of tasks run, skipped and failed, and
+// the CPU time used, for a process
+type ProcessSummary struct {
+	Name         string
+	TasksRun     int
+	TasksSkipped int
+	TasksFailed  int
+	CPUTime      time.Duration
+}
+
+// Summary returns a summary of the run of the workflow so far. Only
+// processes of type *Process (created with NewProc) are included, since other
+// processes don't collect task statistics.
+func (wf *Workflow) Summary() *RunSummary {
+	summary := &RunSummary{
+		Workflow:  wf.Name(),
+		Processes: []ProcessSummary{},
+	}
+	for _, p := range progressProcs(wf.ProcsSorted()) {
+		s := p.Stats()
+		ps := ProcessSummary{
+			Name:         p.Name(),
+			TasksRun:     s.TasksRun,
+			TasksSkipped: s.TasksSkipped,
+			TasksFailed:  s.TasksFailed,
+			CPUTime:      s.Runtime * time.Duration(p.CoresPerTask),
+		}
+		summary.CPUTime += ps.CPUTime
+		summary.Processes = append(summary.Processes, ps)
+	}
+
+	wf.tasksLock.Lock()
+	if !wf.startTime.IsZero() {
+		summary.WallTime = time.Since(wf.startTime)
+	}
+	auditInfos := append([]*AuditInfo{}, wf.taskAuditInfos...)
+	wf.tasksLock.Unlock()
+
+	summary.CriticalPath, summary.CriticalPathTime = criticalPath(auditInfos)
+	return summary
+}
+
+// criticalPath returns the longest chain of tasks, in terms of execution
+// time, among the tasks with the audit infos in auditInfos, where each task
+// has an output of the previous task among its upstream files, together with
+// the total execution time of the chain
+func criticalPath(auditInfos []*AuditInfo) ([]*AuditInfo, time.Duration) {
+	byID := map[string]*AuditInfo{}
+	for _, ai := range auditInfos {
+		byID[ai.ID] = ai
+	}
+
+	// longest contains the execution time of the longest chain ending with
+	// the task with a given ID, and prev the previous task in that chain
+	longest := map[string]time.Duration{}
+	prev := map[string]*AuditInfo{}
+	var visit func(ai *AuditInfo) time.Duration
+	visit = func(ai *AuditInfo) time.Duration {
+		if d, ok := longest[ai.ID]; ok {
+			return d
+		}
+		var best time.Duration
+		for _, path := range sortedAuditInfoMapKeys(ai.Upstream) {
+			up, ok := byID[ai.Upstream[path].ID]
+			if !ok { // The upstream task was not run in this run
+				continue
+			}
+			if d := visit(up); d > best || prev[ai.ID] == nil {
+				best = d
+				prev[ai.ID] = up
+			}
+		}
+		longest[ai.ID] = best + ai.ExecTimeNS
+		return longest[ai.ID]
+	}
+
+	var last *AuditInfo
+	for _, ai := range auditInfos {
+		if last == nil || visit(ai) > longest[last.ID] {
+			last = ai
+		}
+	}
+	if last == nil {
+		return []*AuditInfo{}, 0
+	}
+	path := []*AuditInfo{}
+	for ai := last; ai != nil; ai = prev[ai.ID] {
+		path = append([]*AuditInfo{ai}, path...)
+	}
+	return path, longest[last.ID]
+}
+
+func sortedAuditInfoMapKeys(kv map[string]*AuditInfo) []string {
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String returns the summary formatted as a report, for printing
+func (s *RunSummary) String() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Summary of workflow %s:\n", s.Workflow)
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Process\tRun\tSkipped\tFailed\tCPU time\t")
+	totalRun, totalSkipped, totalFailed := 0, 0, 0
+	for _, ps := range s.Processes {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t\n", ps.Name, ps.TasksRun, ps.TasksSkipped, ps.TasksFailed, roundDuration(ps.CPUTime))
+		totalRun += ps.TasksRun
+		totalSkipped += ps.TasksSkipped
+		totalFailed += ps.TasksFailed
+	}
+	fmt.Fprintf(tw, "Total\t%d\t%d\t%d\t%s\t\n", totalRun, totalSkipped, totalFailed, roundDuration(s.CPUTime))
+	tw.Flush()
+
+	fmt.Fprintf(buf, "Wall clock time: %s, CPU time: %s", roundDuration(s.WallTime), roundDuration(s.CPUTime))
+	if s.WallTime > 0 {
+		fmt.Fprintf(buf, " (%.1f tasks running on average)", float64(s.CPUTime)/float64(s.WallTime))
+	}
+	fmt.Fprintln(buf)
+
+	if len(s.CriticalPath) == 0 {
+		fmt.Fprintln(buf, "Critical path: No tasks run")
+		return buf.String()
+	}
+	fmt.Fprintf(buf, "Critical path (%s, %d tasks):\n", roundDuration(s.CriticalPathTime), len(s.CriticalPath))
+	tw = tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	for _, ai := range s.CriticalPath {
+		share := 0.0
+		if s.CriticalPathTime > 0 {
+			share = 100 * float64(ai.ExecTimeNS) / float64(s.CriticalPathTime)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%.0f%%\t%s\n", ai.ProcessName, roundDuration(ai.ExecTimeNS), share, shortenCommand(ai.Command, 60))
+	}
+	tw.Flush()
+	return buf.String()
+}
+
+// roundDuration rounds d to a precision suitable for the summary
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
+
+// shortenCommand returns cmd on one line, shortened to at most maxLen
+// characters
+func shortenCommand(cmd string, maxLen int) string {
+	cmd = strings.Join(strings.Fields(cmd), " ")
+	if len(cmd) > maxLen {
+		return cmd[:maxLen-3] + "..."
+	}
+	return cmd
+}
+
+// recordTaskAuditInfo keeps the audit info of the task t, which has finished
+// running, for finding the critical path of the run, if the summary is to be
+// printed
+func (wf *Workflow) recordTaskAuditInfo(t *Task) {
+	if t.auditInfo == nil || !wf.PrintSummary {
+		return
+	}
+	wf.tasksLock.Lock()
+	wf.taskAuditInfos = append(wf.taskAuditInfos, t.auditInfo)
+	wf.tasksLock.Unlock()
+}
+
+// printSummary logs the summary of the run, if PrintSummary is set
+func (wf *Workflow) printSummary() {
+	if !wf.PrintSummary {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(wf.Summary().String(), "\n"), "\n") {
+		Audit.Printf("[Workflow:%s] %s\n", wf.Name(), line)
+	}
+}
@@ -0,0 +1,74 @@
+package scipipe
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+	initTestLogs()
+
+	wf, _, bar := newFooBarTestWorkflow("TestSummary_WF", t.TempDir(), "a", "b")
+	wf.PrintSummary = true
+	slow := wf.NewProc("slow", "sleep 0.2; cat {i:in} > {o:out}")
+	slow.In("in").From(bar.Out("out"))
+	slow.SetOut("out", "{i:in}.slow.txt")
+	slow.CoresPerTask = 2
+	wf.Run()
+
+	summary := wf.Summary()
+	procs := map[string]ProcessSummary{}
+	for _, ps := range summary.Processes {
+		procs[ps.Name] = ps
+	}
+	assertEqualValues(t, 2, procs["foo"].TasksRun)
+	assertEqualValues(t, 2, procs["bar"].TasksRun)
+	assertEqualValues(t, 2, procs["slow"].TasksRun)
+	if procs["slow"].CPUTime < 2*2*200*time.Millisecond {
+		t.Errorf("CPU time of slow process should be at least 800ms, but was: %s", procs["slow"].CPUTime)
+	}
+
+	if len(summary.CriticalPath) != 3 {
+		t.Fatalf("Critical path should contain 3 tasks, but was: %v", summary.CriticalPath)
+	}
+	pathTime := time.Duration(0)
+	for i, procName := range []string{"foo", "bar", "slow"} {
+		assertEqualValues(t, procName, summary.CriticalPath[i].ProcessName)
+		pathTime += summary.CriticalPath[i].ExecTimeNS
+	}
+	assertEqualValues(t, pathTime, summary.CriticalPathTime)
+
+	report := summary.String()
+	for _, expected := range []string{"Summary of workflow TestSummary_WF:", "Critical path (", "sleep 0.2; cat"} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("Summary report does not contain %q:\n%s", expected, report)
+		}
+	}
+}
+
+func TestSummaryAuditInfosOnlyKeptWithPrintSummary(t *testing.T) {
+	initTestLogs()
+
+	wf, _, _ := newFooBarTestWorkflow("TestSummaryAuditInfosOnlyKeptWithPrintSummary_WF", t.TempDir(), "a", "b")
+	wf.Run()
+	assertEqualValues(t, 0, len(wf.taskAuditInfos), "Audit infos kept without PrintSummary set")
+}
+
+func TestCriticalPath(t *testing.T) {
+	a := &AuditInfo{ID: "a", ExecTimeNS: 5 * time.Second, Upstream: map[string]*AuditInfo{}}
+	b := &AuditInfo{ID: "b", ExecTimeNS: 1 * time.Second, Upstream: map[string]*AuditInfo{}}
+	c := &AuditInfo{ID: "c", ExecTimeNS: 2 * time.Second, Upstream: map[string]*AuditInfo{"a.txt": a, "b.txt": b}}
+	d := &AuditInfo{ID: "d", ExecTimeNS: 6 * time.Second, Upstream: map[string]*AuditInfo{}}
+	// The audit info of a skipped task, not run in this run
+	skipped := &AuditInfo{ID: "skipped", ExecTimeNS: time.Hour, Upstream: map[string]*AuditInfo{}}
+	e := &AuditInfo{ID: "e", ExecTimeNS: 1 * time.Second, Upstream: map[string]*AuditInfo{"c.txt": c, "s.txt": skipped}}
+
+	path, pathTime := criticalPath([]*AuditInfo{a, b, c, d, e})
+	assertEqualValues(t, []*AuditInfo{a, c, e}, path)
+	assertEqualValues(t, 8*time.Second, pathTime)
+
+	path, pathTime = criticalPath([]*AuditInfo{})
+	assertEqualValues(t, 0, len(path))
+	assertEqualValues(t, time.Duration(0), pathTime)
+}
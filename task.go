@@ -28,6 +28,10 @@ type Task struct {
 	Process       *Process
 	portInfos     map[string]*PortInfo
 	subStreamIPs  map[string][]*FileIP
+	skipped       bool
+	auditInfo     *AuditInfo
+	startTime     time.Time
+	finishTime    time.Time
 }
 
 // ------------------------------------------------------------------------
@@ -257,7 +261,13 @@ func (t *Task) Execute() {
 		t.Failf("Existing temp folders found, so existing. Clean up temporary folders (starting with %s) before restarting the workflow!", tempDirPrefix)
 	}
 
+	if t.Process.ForceRerun {
+		t.removeOldOutputs()
+	}
 	if t.anyOutputsExist() {
+		t.skipped = true
+		t.Process.updateStats(func(s *ProcessStats) { s.TasksSkipped++ })
+		t.workflow.taskSkipped(t)
 		t.Done <- 1
 		return
 	}
@@ -268,22 +278,30 @@ func (t *Task) Execute() {
 	if err != nil {
 		t.Failf("Could not create directories: %v", err)
 	}
-	startTime := time.Now()
+	t.startTime = time.Now()
+	t.Process.updateStats(func(s *ProcessStats) {
+		s.TasksRunning++
+		if s.FirstTaskStarted.IsZero() {
+			s.FirstTaskStarted = t.startTime
+		}
+	})
+	t.workflow.taskStarted(t)
 	if t.CustomExecute != nil {
 		outputsStr := ""
 		for oipName, oip := range t.OutIPs {
 			outputsStr += " " + oipName + ": " + oip.Path()
 		}
-		t.Auditf("Executing: Custom Go function with outputs: %s", outputsStr)
+		t.auditEvent(t.logEntry("task_started"), "Executing: Custom Go function with outputs: %s", outputsStr)
 		t.CustomExecute(t)
-		t.Auditf("Finished: Custom Go function with outputs: %s", outputsStr)
+		t.finishTime = time.Now()
+		t.auditEvent(t.logEntry("task_finished"), "Finished: Custom Go function with outputs: %s", outputsStr)
 	} else {
-		t.Auditf("Executing: %s", t.Command)
+		t.auditEvent(t.logEntry("task_started"), "Executing: %s", t.Command)
 		t.executeCommand(t.Command)
-		t.Auditf("Finished: %s", t.Command)
+		t.finishTime = time.Now()
+		t.auditEvent(t.logEntry("task_finished"), "Finished: %s", t.Command)
 	}
-	finishTime := time.Now()
-	t.writeAuditLogs(startTime, finishTime)
+	t.writeAuditLogs(t.startTime, t.finishTime)
 
 	t.ensureAllOutputsExist()
 	finErr := t.finalizePaths()
@@ -292,6 +310,13 @@ func (t *Task) Execute() {
 	}
 
 	t.workflow.DecConcurrentTasks(t.cores)
+	t.Process.updateStats(func(s *ProcessStats) {
+		s.TasksRunning--
+		s.TasksRun++
+		s.Runtime += t.finishTime.Sub(t.startTime)
+		s.LastTaskFinished = t.finishTime
+	})
+	t.workflow.taskFinished(t)
 
 	t.Done <- 1
 }
@@ -315,7 +340,7 @@ func (t *Task) anyOutputsExist() (anyFileExists bool) {
 		if !oip.doStream {
 			opath := oip.Path()
 			if _, err := os.Stat(opath); err == nil {
-				t.Auditf("Output file already exists, so skipping: %s", opath)
+				t.auditEvent(t.logEntry("task_skipped"), "Output file already exists, so skipping: %s", opath)
 				anyFileExists = true
 			}
 		}
@@ -323,6 +348,37 @@ func (t *Task) anyOutputsExist() (anyFileExists bool) {
 	return
 }
 
+// removeOldOutputs moves any existing outputs of the task, and their audit
+// files, aside, or deletes them if Workflow.DeleteOldOutputs is true, so that
+// the task can be re-run
+func (t *Task) removeOldOutputs() {
+	suffix := ".old." + time.Now().Format("20060102-150405")
+	for _, oname := range sortedFileIPMapKeys(t.OutIPs) {
+		oip := t.OutIPs[oname]
+		if oip.doStream {
+			continue
+		}
+		for _, path := range []string{oip.Path(), oip.AuditFilePath()} {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if t.workflow.DeleteOldOutputs {
+				t.Auditf("Deleting old output, to force re-run: %s", path)
+				err := os.RemoveAll(path)
+				if err != nil {
+					t.Failf("Could not delete old output %s: %v", path, err)
+				}
+			} else {
+				t.Auditf("Moving old output aside, to force re-run: %s -> %s", path, path+suffix)
+				err := os.Rename(path, path+suffix)
+				if err != nil {
+					t.Failf("Could not move old output %s aside: %v", path, err)
+				}
+			}
+		}
+	}
+}
+
 // createDirs creates directories for out-IPs of the task
 func (t *Task) createDirs() error {
 	err := os.MkdirAll(t.TempDir(), 0777)
@@ -364,6 +420,7 @@ func (t *Task) writeAuditLogs(startTime time.Time, finishTime time.Time) {
 	auditInfo.StartTime = startTime
 	auditInfo.FinishTime = finishTime
 	auditInfo.ExecTimeNS = finishTime.Sub(startTime)
+	t.auditInfo = auditInfo
 	// Set the audit infos from incoming IPs into the "Upstream" map
 	for inpName, iip := range t.InIPs {
 		if t.portInfos[inpName].join {
@@ -413,12 +470,46 @@ func (t *Task) Audit(msg string) {
 	Audit.Printf("[Task:%s] %s", t.Process.Name(), msg)
 }
 
+// auditEvent logs an event for the task to the Audit logger, with the message
+// msg formatted with parts (See logEvent)
+func (t *Task) auditEvent(entry LogEntry, msg string, parts ...interface{}) {
+	logEvent(Audit, entry, fmt.Sprintf("[Task:%s] %s\n", t.Process.Name(), fmt.Sprintf(msg, parts...)))
+}
+
+// logEntry returns a LogEntry with the event type event, and the fields for
+// the task filled in, for use with the JSON log format
+func (t *Task) logEntry(event string) LogEntry {
+	entry := LogEntry{
+		Event:    event,
+		Workflow: t.workflow.Name(),
+		Process:  t.Process.Name(),
+		Task:     t.TempDir(),
+		Command:  t.Command,
+	}
+	if t.CustomExecute != nil {
+		entry.Command = ""
+	}
+	if !t.finishTime.IsZero() {
+		entry.DurationS = t.finishTime.Sub(t.startTime).Seconds()
+	}
+	return entry
+}
+
 func (t *Task) Failf(msg string, parts ...interface{}) {
 	t.Fail(fmt.Sprintf(msg+"\n", parts...))
 }
 
 func (t *Task) Fail(msg interface{}) {
-	Failf("[Task:%s] %s", t.Process.Name(), msg)
+	t.Process.updateStats(func(s *ProcessStats) {
+		s.TasksFailed++
+		if !t.startTime.IsZero() { // The task was running when it failed
+			s.TasksRunning--
+		}
+	})
+	t.workflow.taskFailed(t, msg)
+	entry := t.logEntry("task_failed")
+	entry.Error = strings.TrimSpace(fmt.Sprintf("%v", msg))
+	failEvent(&entry, fmt.Sprintf("[Task:%s] %s\n", t.Process.Name(), msg))
 }
 
 // FinalizePaths renames temporary output files/directories to their proper paths.
@@ -0,0 +1,147 @@
+package scipipe
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// Chrome trace event export
+// ----------------------------------------------------------------------------
+
+const (
+	// tracePidProcesses is the trace "process" containing one track per
+	// workflow process
+	tracePidProcesses = 1
+	// tracePidSlots is the trace "process" containing one track per slot for
+	// concurrent tasks
+	tracePidSlots = 2
+)
+
+// traceEvent is an event in the Chrome trace event format, as read by
+// chrome://tracing and Perfetto (https://ui.perfetto.dev). Times are in
+// microseconds.
+type traceEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   int64                  `json:"ts"`
+	Dur  int64                  `json:"dur,omitempty"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// traceFile is the top level object of a trace file
+type traceFile struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// WriteTrace writes a trace of the tasks executed so far in the run of the
+// workflow to w, in the Chrome trace event format, which can be opened in
+// chrome://tracing or Perfetto (https://ui.perfetto.dev). The trace contains
+// a span for each task (skipped tasks are not included), with its command,
+// params and outputs, shown both on one track per process, and on one track
+// per slot for concurrent tasks. Tasks are placed on the slot tracks in the
+// order they started, so a task using several slots (See
+// Process.CoresPerTask) is shown on only one of them. The completed tasks are
+// only kept in memory if TraceFile is set.
+func (wf *Workflow) WriteTrace(w io.Writer) error {
+	wf.tasksLock.Lock()
+	startTime := wf.startTime
+	tasks := []TaskStatus{}
+	for _, ts := range wf.completedTasks {
+		if !ts.Skipped {
+			tasks = append(tasks, ts)
+		}
+	}
+	tasks = append(tasks, wf.failedTasks...)
+	wf.tasksLock.Unlock()
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].StartTime.Before(tasks[j].StartTime)
+	})
+
+	events := []traceEvent{
+		{Name: "process_name", Ph: "M", Pid: tracePidProcesses, Args: map[string]interface{}{"name": "Processes"}},
+		{Name: "process_name", Ph: "M", Pid: tracePidSlots, Args: map[string]interface{}{"name": "Slots"}},
+	}
+
+	procTids := map[string]int{}
+	for _, p := range wf.statusProcs(wf.ProcsSorted()) {
+		procTids[p.Name()] = len(procTids) + 1
+		events = append(events, traceEvent{Name: "thread_name", Ph: "M", Pid: tracePidProcesses, Tid: procTids[p.Name()], Args: map[string]interface{}{"name": p.Name()}})
+	}
+
+	// slotsFreeAt contains the time at which each slot track becomes free
+	slotsFreeAt := []time.Time{}
+	for _, ts := range tasks {
+		if ts.StartTime.IsZero() { // Failed before starting
+			continue
+		}
+		slot := -1
+		for i, freeAt := range slotsFreeAt {
+			if !freeAt.After(ts.StartTime) {
+				slot = i
+				break
+			}
+		}
+		if slot == -1 {
+			slot = len(slotsFreeAt)
+			slotsFreeAt = append(slotsFreeAt, time.Time{})
+			events = append(events, traceEvent{Name: "thread_name", Ph: "M", Pid: tracePidSlots, Tid: slot + 1, Args: map[string]interface{}{"name": "Slot " + strconv.Itoa(slot+1)}})
+		}
+		slotsFreeAt[slot] = ts.FinishTime
+
+		args := map[string]interface{}{
+			"command": ts.Command,
+			"params":  ts.Params,
+			"outputs": ts.OutFiles,
+		}
+		cat := "task"
+		if ts.Error != "" {
+			cat = "task,failed"
+			args["error"] = ts.Error
+		}
+		tsMicros := ts.StartTime.Sub(startTime).Microseconds()
+		durMicros := ts.FinishTime.Sub(ts.StartTime).Microseconds()
+		if durMicros < 1 {
+			durMicros = 1
+		}
+		procTid, ok := procTids[ts.Process]
+		if !ok {
+			procTid = len(procTids) + 1
+			procTids[ts.Process] = procTid
+		}
+		events = append(events,
+			traceEvent{Name: ts.Process, Cat: cat, Ph: "X", Ts: tsMicros, Dur: durMicros, Pid: tracePidProcesses, Tid: procTid, Args: args},
+			traceEvent{Name: ts.Process, Cat: cat, Ph: "X", Ts: tsMicros, Dur: durMicros, Pid: tracePidSlots, Tid: slot + 1, Args: args},
+		)
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(traceFile{TraceEvents: events, DisplayTimeUnit: "ms"})
+}
+
+// writeTraceFile writes the trace of the run to the file in TraceFile, if set
+func (wf *Workflow) writeTraceFile() {
+	if wf.TraceFile == "" {
+		return
+	}
+	createDirs(wf.TraceFile)
+	f, err := os.Create(wf.TraceFile)
+	if err != nil {
+		Error.Printf("[Workflow:%s] Could not create trace file %s: %v\n", wf.Name(), wf.TraceFile, err)
+		return
+	}
+	defer f.Close()
+	err = wf.WriteTrace(f)
+	if err != nil {
+		Error.Printf("[Workflow:%s] Could not write trace to %s: %v\n", wf.Name(), wf.TraceFile, err)
+	}
+}
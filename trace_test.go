@@ -0,0 +1,80 @@
+package scipipe
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTraceFile(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	tracePath := filepath.Join(dir, "trace.json")
+	wf, _, _ := newFooBarTestWorkflow("TestTraceFile_WF", dir, "a", "b", "c")
+	wf.TraceFile = tracePath
+	wf.Run()
+
+	traceJSON, err := ioutil.ReadFile(tracePath)
+	if err != nil {
+		t.Fatalf("Could not read trace file: %v", err)
+	}
+	trace := traceFile{}
+	if err := json.Unmarshal(traceJSON, &trace); err != nil {
+		t.Fatalf("Could not parse trace file: %v", err)
+	}
+
+	procSpans := 0
+	slotSpans := 0
+	slots := map[int]bool{}
+	for _, e := range trace.TraceEvents {
+		if e.Ph != "X" {
+			continue
+		}
+		if e.Name != "foo" && e.Name != "bar" {
+			t.Errorf("Unexpected span name: %s", e.Name)
+		}
+		if e.Args["command"] == "" {
+			t.Errorf("Command not set for span: %v", e)
+		}
+		switch e.Pid {
+		case tracePidProcesses:
+			procSpans++
+		case tracePidSlots:
+			slotSpans++
+			slots[e.Tid] = true
+		}
+	}
+	assertEqualValues(t, 6, procSpans)
+	assertEqualValues(t, 6, slotSpans)
+	if len(slots) > 4 {
+		t.Errorf("More slot tracks (%d) than concurrent tasks allowed (4)", len(slots))
+	}
+}
+
+func TestTraceTasksOnlyKeptWithTraceFile(t *testing.T) {
+	initTestLogs()
+
+	wf, _, _ := newFooBarTestWorkflow("TestTraceTasksOnlyKeptWithTraceFile_WF", t.TempDir(), "a", "b", "c")
+	wf.Run()
+	assertEqualValues(t, 0, len(wf.completedTasks), "Completed tasks kept without TraceFile set")
+}
+
+func TestTraceFileWrittenOnFailure(t *testing.T) {
+	initTestLogs()
+	dir := t.TempDir()
+
+	wf, _, _ := newFooBarTestWorkflow("TestTraceFileWrittenOnFailure_WF", dir, "a")
+	wf.TraceFile = filepath.Join(dir, "trace.json")
+	// recordFailedRuns is what Fail calls before exiting, so call it as if
+	// the workflow was running when the failure happened
+	addRunningWorkflow(wf)
+	recordFailedRuns()
+	removeRunningWorkflow(wf)
+
+	if _, err := os.Stat(wf.TraceFile); err != nil {
+		t.Errorf("Trace file was not written: %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package scipipe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Workflow validation
+// ----------------------------------------------------------------------------
+
+// Validate checks the workflow graph for problems that would prevent it from
+// running properly, and returns all problems found, rather than stopping at
+// the first one. The following checks are done:
+//   - All in-ports and param in-ports are connected (Out-ports and param
+//     out-ports left unconnected are connected to the sink when the workflow
+//     is run, so they are not reported)
+//   - No connection is made more than once
+//   - There are no cycles in the graph
+//   - All processes are reachable (upstream) from the sink, or from a process
+//     without out-ports, which will drive the workflow
+//   - Placeholders in path patterns set with Process.SetOut() refer to ports
+//     that exist in the process
+//   - Ports set with Process.Broadcast() exist in the process
+//
+// An empty slice is returned if no problems were found.
+func (wf *Workflow) Validate() []error {
+	return wf.validate(wf.procs)
+}
+
+// validate runs the checks described for Validate, on the processes in procs
+func (wf *Workflow) validate(procs map[string]WorkflowProcess) []error {
+	errs := []error{}
+
+	if len(procs) == 0 {
+		errs = append(errs, fmt.Errorf("The workflow is empty. Did you forget to add the processes to it?"))
+		return errs
+	}
+	if wf.sink == nil {
+		errs = append(errs, fmt.Errorf("The workflow sink is nil"))
+		return errs
+	}
+
+	procsToCheck := mergeWFMaps(map[string]WorkflowProcess{}, procs)
+	sortedProcs := sortedWFMapValues(procsToCheck)
+
+	for _, proc := range sortedProcs {
+		errs = append(errs, validateProc(proc)...)
+	}
+	errs = append(errs, findCycles(sortedProcs)...)
+	errs = append(errs, wf.findUnreachableProcs(procsToCheck)...)
+
+	return errs
+}
+
+// validateProc returns errors for problems with the ports of proc, and for
+// processes and sub-workflows, with the path patterns and inner processes
+// respectively
+func validateProc(proc WorkflowProcess) []error {
+	errs := validatePortConnections(proc)
+	switch p := proc.(type) {
+	case *Process:
+		errs = append(errs, validatePathPatterns(p)...)
+		errs = append(errs, validateBroadcastPorts(p)...)
+	case *SubWorkflow:
+		errs = append(errs, p.validate()...)
+	}
+	return errs
+}
+
+// validatePortConnections returns errors for all unconnected, or multiply
+// connected, in-ports and param in-ports of proc. Unconnected out-ports and
+// param out-ports are not errors, since they are connected to the sink when
+// the workflow is run (See reconnectDeadEndConnections).
+func validatePortConnections(proc WorkflowProcess) []error {
+	errs := []error{}
+	inPorts := proc.InPorts()
+	for _, name := range sortedInPortMapKeys(inPorts) {
+		ipt := inPorts[name]
+		if !ipt.Ready() {
+			errs = append(errs, fmt.Errorf("[Process:%s] In-port (%s) is not connected", proc.Name(), name))
+		}
+		for _, rptName := range ipt.duplicateRemotePorts {
+			errs = append(errs, fmt.Errorf("[Process:%s] In-port (%s) is connected more than once to out-port (%s)", proc.Name(), name, rptName))
+		}
+	}
+	inParamPorts := proc.InParamPorts()
+	for _, name := range sortedInParamPortMapKeys(inParamPorts) {
+		pip := inParamPorts[name]
+		if !pip.Ready() {
+			errs = append(errs, fmt.Errorf("[Process:%s] Param in-port (%s) is not connected", proc.Name(), name))
+		}
+		for _, popName := range pip.duplicateRemotePorts {
+			errs = append(errs, fmt.Errorf("[Process:%s] Param in-port (%s) is connected more than once to param out-port (%s)", proc.Name(), name, popName))
+		}
+	}
+	return errs
+}
+
+// validateBroadcastPorts returns errors for all ports set with Broadcast,
+// that are neither in-ports nor param in-ports of p
+func validateBroadcastPorts(p *Process) []error {
+	errs := []error{}
+	for _, portName := range sortedBoolMapKeys(p.broadcastPorts) {
+		_, isInPort := p.InPorts()[portName]
+		_, isInParamPort := p.InParamPorts()[portName]
+		if !isInPort && !isInParamPort {
+			errs = append(errs, fmt.Errorf("[Process:%s] Broadcast port (%s) is neither an in-port nor a param in-port", p.Name(), portName))
+		}
+	}
+	return errs
+}
+
+// validatePathPatterns returns errors for all placeholders in the path
+// patterns of p (set with SetOut), that refer to non-existing ports
+func validatePathPatterns(p *Process) []error {
+	errs := []error{}
+	r := getShellCommandPlaceHolderRegex()
+	for _, outName := range sortedStringMapKeys(p.outPathPatterns) {
+		pattern := p.outPathPatterns[outName]
+		for _, m := range r.FindAllStringSubmatch(pattern, -1) {
+			phType := m[1]
+			portName := strings.Split(m[2], "|")[0]
+			exists := true
+			switch phType {
+			case "i":
+				_, exists = p.InPorts()[portName]
+			case "o":
+				_, exists = p.PathFuncs[portName]
+			case "p":
+				_, exists = p.InParamPorts()[portName]
+			case "t":
+				// Tags are only known at runtime, so they can't be checked here
+			default:
+				errs = append(errs, fmt.Errorf("[Process:%s] Path pattern (%s) for out-port (%s) contains unsupported placeholder (%s)", p.Name(), pattern, outName, m[0]))
+				continue
+			}
+			if !exists {
+				errs = append(errs, fmt.Errorf("[Process:%s] Path pattern (%s) for out-port (%s) refers to non-existing port in placeholder (%s)", p.Name(), pattern, outName, m[0]))
+			}
+		}
+	}
+	return errs
+}
+
+// findCycles returns one error for each cycle found among the connections
+// between the processes in procs
+func findCycles(procs []WorkflowProcess) []error {
+	errs := []error{}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[WorkflowProcess]int{}
+	path := []WorkflowProcess{}
+
+	var visit func(proc WorkflowProcess)
+	visit = func(proc WorkflowProcess) {
+		state[proc] = visiting
+		path = append(path, proc)
+		for _, next := range downstreamProcsSorted(proc) {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				names := []string{}
+				for i := len(path) - 1; i >= 0; i-- {
+					names = append([]string{path[i].Name()}, names...)
+					if path[i] == next {
+						break
+					}
+				}
+				names = append(names, next.Name())
+				errs = append(errs, fmt.Errorf("Cycle found in workflow: %s", strings.Join(names, " -> ")))
+			}
+		}
+		path = path[:len(path)-1]
+		state[proc] = visited
+	}
+
+	for _, proc := range procs {
+		if state[proc] == unvisited {
+			visit(proc)
+		}
+	}
+	return errs
+}
+
+// findUnreachableProcs returns errors for all processes in procs that can't
+// be reached by following connections upstream from the sink, or from any of
+// the processes that will be connected to the sink, or be used as driver, when
+// the workflow is run
+func (wf *Workflow) findUnreachableProcs(procs map[string]WorkflowProcess) []error {
+	reached := map[WorkflowProcess]bool{}
+
+	var visit func(proc WorkflowProcess)
+	visit = func(proc WorkflowProcess) {
+		if reached[proc] {
+			return
+		}
+		reached[proc] = true
+		for _, prev := range upstreamProcsSorted(proc) {
+			visit(prev)
+		}
+	}
+
+	visit(wf.sink)
+	for _, proc := range sortedWFMapValues(procs) {
+		if isTerminalProc(proc) {
+			visit(proc)
+			continue
+		}
+		for _, opt := range proc.OutPorts() {
+			if !opt.Ready() {
+				visit(proc)
+			}
+		}
+		for _, pop := range proc.OutParamPorts() {
+			if !pop.Ready() {
+				visit(proc)
+			}
+		}
+	}
+
+	errs := []error{}
+	for _, proc := range sortedWFMapValues(procs) {
+		if !reached[proc] {
+			errs = append(errs, fmt.Errorf("[Process:%s] Process is not reachable from the workflow sink, and so will never be run", proc.Name()))
+		}
+	}
+	return errs
+}
+
+// downstreamProcsSorted returns the processes directly connected to the
+// out-ports and param out-ports of proc, sorted by name
+func downstreamProcsSorted(proc WorkflowProcess) []WorkflowProcess {
+	procs := map[string]WorkflowProcess{}
+	for _, opt := range proc.OutPorts() {
+		for _, rpt := range opt.RemotePorts {
+			if rpt.process != nil {
+				procs[rpt.process.Name()] = rpt.process
+			}
+		}
+	}
+	for _, pop := range proc.OutParamPorts() {
+		for _, rpt := range pop.RemotePorts {
+			if rpt.process != nil {
+				procs[rpt.process.Name()] = rpt.process
+			}
+		}
+	}
+	return sortedWFMapValues(procs)
+}
+
+// upstreamProcsSorted returns the processes directly connected to the
+// in-ports and param in-ports of proc, sorted by name. The close locks of the
+// ports are held while reading their connections, since connections from
+// e.g. InParamPort.FromStr can be closed before the workflow is started.
+func upstreamProcsSorted(proc WorkflowProcess) []WorkflowProcess {
+	procs := map[string]WorkflowProcess{}
+	for _, ipt := range proc.InPorts() {
+		ipt.closeLock.Lock()
+		for _, rpt := range ipt.RemotePorts {
+			if rpt.process != nil {
+				procs[rpt.process.Name()] = rpt.process
+			}
+		}
+		ipt.closeLock.Unlock()
+	}
+	for _, pip := range proc.InParamPorts() {
+		pip.closeLock.Lock()
+		for _, rpt := range pip.RemotePorts {
+			if rpt.process != nil {
+				procs[rpt.process.Name()] = rpt.process
+			}
+		}
+		pip.closeLock.Unlock()
+	}
+	return sortedWFMapValues(procs)
+}
+
+func sortedWFMapValues(procs map[string]WorkflowProcess) []WorkflowProcess {
+	names := []string{}
+	for name := range procs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sorted := []WorkflowProcess{}
+	for _, name := range names {
+		sorted = append(sorted, procs[name])
+	}
+	return sorted
+}
+
+func sortedInPortMapKeys(kv map[string]*InPort) []string {
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedOutPortMapKeys(kv map[string]*OutPort) []string {
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedInParamPortMapKeys(kv map[string]*InParamPort) []string {
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedOutParamPortMapKeys(kv map[string]*OutParamPort) []string {
+	keys := []string{}
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+package scipipe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateConnectedWorkflow(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestValidateConnectedWorkflow", 4)
+
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	foo.SetOut("out", ".tmp/foo.txt")
+
+	f2b := wf.NewProc("f2b", "sed 's/foo/bar/g' {i:in} > {o:out} # {p:note}")
+	f2b.SetOut("out", "{i:in|%.txt}.{p:note}.bar.txt")
+	f2b.In("in").From(foo.Out("out"))
+	f2b.InParam("note").FromStr("hej")
+	wf.Sink().From(f2b.Out("out"))
+
+	errs := wf.Validate()
+	if len(errs) != 0 {
+		t.Errorf("Expected no validation errors, but got: %v", errs)
+	}
+}
+
+func TestValidateReportsAllUnconnectedPorts(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestValidateReportsAllUnconnectedPorts", 4)
+
+	wf.NewProc("cat", "cat {i:in1} {i:in2} {p:flag} > {o:out}")
+
+	assertErrorsContain(t, wf.Validate(),
+		"In-port (in1) is not connected",
+		"In-port (in2) is not connected",
+		"Param in-port (flag) is not connected",
+	)
+}
+
+func TestValidateMinimalWorkflow(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestValidateMinimalWorkflow", 4)
+
+	// The out-port of bar is left unconnected, since it is connected to the
+	// sink by Run
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	foo.SetOut("out", ".tmp/foo.txt")
+	bar := wf.NewProc("bar", "cat {i:in} > {o:out}")
+	bar.In("in").From(foo.Out("out"))
+	bar.SetOut("out", "{i:in}.bar.txt")
+
+	errs := wf.Validate()
+	if len(errs) != 0 {
+		t.Errorf("Expected no validation errors, but got: %v", errs)
+	}
+}
+
+func TestValidateFindsCycles(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestValidateFindsCycles", 4)
+
+	a := wf.NewProc("a", "cat {i:in} > {o:out}")
+	b := wf.NewProc("b", "cat {i:in} > {o:out}")
+	a.Out("out").To(b.In("in"))
+	b.Out("out").To(a.In("in"))
+
+	assertErrorsContain(t, wf.Validate(),
+		"Cycle found in workflow: a -> b -> a",
+		"[Process:a] Process is not reachable from the workflow sink",
+		"[Process:b] Process is not reachable from the workflow sink",
+	)
+}
+
+func TestValidateFindsDuplicateConnections(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestValidateFindsDuplicateConnections", 4)
+
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	cat := wf.NewProc("cat", "cat {i:in} > {o:out}")
+	cat.In("in").From(foo.Out("out"))
+	cat.In("in").From(foo.Out("out"))
+
+	assertErrorsContain(t, wf.Validate(),
+		"In-port (in) is connected more than once to out-port (foo.out)",
+	)
+}
+
+func TestValidateFindsInvalidPathPlaceholders(t *testing.T) {
+	initTestLogs()
+	wf := NewWorkflow("TestValidateFindsInvalidPathPlaceholders", 4)
+
+	foo := wf.NewProc("foo", "echo foo > {o:out}")
+	cat := wf.NewProc("cat", "cat {i:in} > {o:out}")
+	cat.SetOut("out", "{i:inn}.{p:nonexisting}.{t:sometag}.txt")
+	cat.In("in").From(foo.Out("out"))
+	wf.Sink().From(cat.Out("out"))
+
+	errs := wf.Validate()
+	assertErrorsContain(t, errs,
+		"refers to non-existing port in placeholder ({i:inn})",
+		"refers to non-existing port in placeholder ({p:nonexisting})",
+	)
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 validation errors, but got %d: %v", len(errs), errs)
+	}
+}
+
+func TestRunFailsOnValidationErrors(t *testing.T) {
+	ensureFailsProgram("TestRunFailsOnValidationErrors", func() {
+		wf := NewWorkflow("TestRunFailsOnValidationErrors", 4)
+		cat := wf.NewProc("cat", "cat {i:in} > {o:out}")
+		cat.SetOut("out", ".tmp/cat_out.txt")
+		wf.Run()
+	}, t)
+}
+
+func assertErrorsContain(t *testing.T, errs []error, expectedMsgs ...string) {
+	for _, msg := range expectedMsgs {
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), msg) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected an error containing '%s', but got: %v", msg, errs)
+		}
+	}
+}
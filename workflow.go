@@ -31,15 +31,63 @@ type Workflow struct {
 	concurrentTasks   chan struct{}
 	concurrentTasksMx sync.Mutex
 	sink              *Sink
-	driver            WorkflowProcess
 	logFile           string
 	PlotConf          WorkflowPlotConf
-}
-
-// WorkflowPlotConf contains configuraiton for plotting the workflow as a graph
-// with graphviz
+	// DeleteOldOutputs makes processes with ForceRerun set delete existing
+	// outputs (and their audit files) before re-running, rather than moving
+	// them aside, by adding a suffix like .old.20060102-150405 to their paths
+	DeleteOldOutputs bool
+	// ShowProgress enables showing the number of queued, running, done and
+	// skipped tasks of each process while the workflow runs, as a live view
+	// if stdout is a terminal, and otherwise as plain text summaries printed
+	// periodically
+	ShowProgress bool
+	// PrintSummary enables logging a summary of the run when the workflow
+	// finishes (See Summary). The audit infos of the tasks, needed for the
+	// critical path, are only kept in memory when it is set.
+	PrintSummary bool
+	// TraceFile is the path of a file to write a trace of the run to, in the
+	// Chrome trace event format (See WriteTrace), if set. The completed tasks
+	// needed for the trace are only kept in memory when it is set.
+	TraceFile string
+	// runEdges is a snapshot of the connections in the workflow, taken when
+	// the workflow starts running, since connections are removed as ports
+	// are closed
+	runEdges     []graphEdge
+	runEdgesLock sync.Mutex
+	// lockFile is the lock file (See LockFileName) held while running
+	lockFile *os.File
+	// startTime, the running, recently completed and failed tasks, and the
+	// task metrics per process, are recorded for the status API (See
+	// ServeStatus). All completed tasks are only recorded for the trace (See
+	// TraceFile), the output paths for the run history (See HistoryFile), and
+	// the audit infos of finished tasks for the summary (See PrintSummary).
+	startTime      time.Time
+	tasksLock      sync.Mutex
+	runningTasks   map[*Task]*TaskStatus
+	tasksCompleted int
+	recentTasks    taskStatusRing
+	completedTasks []TaskStatus
+	failedTasks    []TaskStatus
+	outputPaths    []string
+	metrics        map[string]*processMetrics
+	taskAuditInfos []*AuditInfo
+	// runID identifies the run in the run history (See HistoryFile), and
+	// historyWritten is set when the run has been recorded there
+	runID          string
+	historyWritten bool
+	// hooks are the functions registered with the On... methods
+	hooks workflowHooks
+}
+
+// WorkflowPlotConf contains configuraiton for plotting the workflow as a graph,
+// in the DOT, Mermaid or SVG formats
 type WorkflowPlotConf struct {
 	EdgeLabels bool
+	// StatusGraphFile is the path of a DOT file to write the status graph
+	// (See StatusDotGraph) to, when the workflow has finished, or when the run
+	// fails. It is not written if empty.
+	StatusGraphFile string
 }
 
 // WorkflowProcess is an interface for processes to be handled by Workflow
@@ -91,7 +139,6 @@ func newWorkflowWithoutLogging(name string, maxConcurrentTasks int) *Workflow {
 	}
 	sink := NewSink(wf, name+"_default_sink")
 	wf.sink = sink
-	wf.driver = sink
 	return wf
 }
 
@@ -191,6 +238,30 @@ func (wf *Workflow) DecConcurrentTasks(slots int) {
 	}
 }
 
+// BufferStats returns the statistics about the usage of the channel buffers
+// (See InPort.BufferStats) of all in-ports and param in-ports of the
+// processes in the workflow, including those in sub-workflows, by the full
+// name of the port (PROCNAME.PORTNAME)
+func (wf *Workflow) BufferStats() map[string]BufferStats {
+	stats := map[string]BufferStats{}
+	var addStats func(procs map[string]WorkflowProcess)
+	addStats = func(procs map[string]WorkflowProcess) {
+		for _, proc := range procs {
+			for _, ipt := range proc.InPorts() {
+				stats[ipt.Name()] = ipt.BufferStats()
+			}
+			for _, pip := range proc.InParamPorts() {
+				stats[pip.Name()] = pip.BufferStats()
+			}
+			if sw, ok := proc.(*SubWorkflow); ok {
+				addStats(sw.Procs())
+			}
+		}
+	}
+	addStats(wf.procs)
+	return stats
+}
+
 // PlotGraph writes the workflow structure to a dot file
 func (wf *Workflow) PlotGraph(filePath string) {
 	dot := wf.DotGraph()
@@ -215,6 +286,7 @@ func (wf *Workflow) PlotGraphPDF(filePath string) {
 // (See https://en.wikipedia.org/wiki/DOT_%28graph_description_language%29)
 // If Workflow.PlotConf.EdgeLabels is set to true, a label containing the
 // in-port and out-port to which edges are connected to, will be printed.
+// Sub-workflows are drawn as clusters containing their inner processes.
 func (wf *Workflow) DotGraph() (dot string) {
 	dot = fmt.Sprintf(`digraph "%s" {`+"\n", wf.Name())
 	dot += `  rankdir=LR;` + "\n"
@@ -223,35 +295,55 @@ func (wf *Workflow) DotGraph() (dot string) {
 	dot += `  edge  [fontname="Arial",fontsize=9, color="#384A52",fontcolor="#384A52"];` + "\n"
 
 	con := ""
-	remToDotPtn := regexp.MustCompile(`^.*\.`)
 	for _, p := range wf.ProcsSorted() {
-		dot += fmt.Sprintf(`  "%s" [shape=box];`+"\n", p.Name())
-		// File connections
-		for opname, op := range p.OutPorts() {
-			for rpname, rp := range op.RemotePorts {
-				if wf.PlotConf.EdgeLabels {
-					con += fmt.Sprintf(`  "%s" -> "%s" [taillabel="%s", headlabel="%s"];`+"\n", op.Process().Name(), rp.Process().Name(), remToDotPtn.ReplaceAllString(opname, ""), remToDotPtn.ReplaceAllString(rpname, ""))
-				} else {
-					con += fmt.Sprintf(`  "%s" -> "%s";`+"\n", op.Process().Name(), rp.Process().Name())
-				}
-			}
-		}
-		// Parameter connections
-		for popname, pop := range p.OutParamPorts() {
-			for rpname, rp := range pop.RemotePorts {
-				if wf.PlotConf.EdgeLabels {
-					con += fmt.Sprintf(`  "%s" -> "%s" [style="dashed", taillabel="%s", headlabel="%s"];`+"\n", pop.Process().Name(), rp.Process().Name(), remToDotPtn.ReplaceAllString(popname, ""), remToDotPtn.ReplaceAllString(rpname, ""))
-				} else {
-					con += fmt.Sprintf(`  "%s" -> "%s" [style="dashed"];`+"\n", pop.Process().Name(), rp.Process().Name())
-				}
-			}
-		}
+		dot += wf.dotNodes(p, "  ")
+		con += wf.dotEdges(p)
 	}
 	dot += con
 	dot += "}\n"
 	return
 }
 
+// dotNodes returns the DOT node definition for the process p, or a cluster
+// with the inner processes, if p is a sub-workflow
+func (wf *Workflow) dotNodes(p WorkflowProcess, indent string) (dot string) {
+	sw, ok := p.(*SubWorkflow)
+	if !ok {
+		return fmt.Sprintf(`%s"%s" [shape=box];`+"\n", indent, p.Name())
+	}
+	dot = fmt.Sprintf(`%ssubgraph "cluster_%s" {`+"\n", indent, sw.Name())
+	dot += fmt.Sprintf(`%s  label="%s";`+"\n", indent, sw.Name())
+	for _, inner := range sw.ProcsSorted() {
+		dot += wf.dotNodes(inner, indent+"  ")
+	}
+	dot += indent + "}\n"
+	return dot
+}
+
+// dotEdges returns the DOT edge definitions for the out-ports of p. For
+// sub-workflows, the edges of the inner processes are returned, and
+// connections via exposed ports are drawn directly to the inner processes.
+func (wf *Workflow) dotEdges(p WorkflowProcess) (con string) {
+	for _, e := range graphEdges(p) {
+		style := ""
+		if e.isParam {
+			style = `style="dashed"`
+		}
+		if wf.PlotConf.EdgeLabels {
+			if style != "" {
+				style += ", "
+			}
+			style += fmt.Sprintf(`taillabel="%s", headlabel="%s"`, e.fromPort, e.toPort)
+		}
+		if style != "" {
+			con += fmt.Sprintf(`  "%s" -> "%s" [%s];`+"\n", e.fromProc, e.toProc, style)
+		} else {
+			con += fmt.Sprintf(`  "%s" -> "%s";`+"\n", e.fromProc, e.toProc)
+		}
+	}
+	return con
+}
+
 // ----------------------------------------------------------------------------
 // Run methods
 // ----------------------------------------------------------------------------
@@ -298,45 +390,97 @@ func (wf *Workflow) RunToProcs(finalProcs ...WorkflowProcess) {
 	wf.runProcs(procsToRun)
 }
 
+// RunFrom runs the whole workflow, but forces the processes with names
+// provided as arguments, and all processes downstream of them, to re-run
+// their tasks, even if their outputs already exist (See Process.ForceRerun).
+// This is useful for example after fixing a bug in one step of a workflow.
+func (wf *Workflow) RunFrom(procNames ...string) {
+	procs := []WorkflowProcess{}
+	for _, procName := range procNames {
+		procs = append(procs, wf.Proc(procName))
+	}
+	wf.RunFromProcs(procs...)
+}
+
+// RunFromProcs runs the whole workflow, but forces the process structs
+// provided as arguments, and all processes downstream of them, to re-run
+// their tasks, even if their outputs already exist
+func (wf *Workflow) RunFromProcs(fromProcs ...WorkflowProcess) {
+	for _, fromProc := range fromProcs {
+		setForceRerun(fromProc)
+		for _, proc := range downstreamProcsForProc(fromProc) {
+			setForceRerun(proc)
+		}
+	}
+	wf.Run()
+}
+
 // ----------------------------------------------------------------------------
 // Helper methods for running the workflow
 // ----------------------------------------------------------------------------
 
 // runProcs runs a specified set of processes only
 func (wf *Workflow) runProcs(procs map[string]WorkflowProcess) {
+	if err := wf.lock(); err != nil {
+		wf.Fail(err)
+	}
+	defer wf.unlock()
+
 	wf.reconnectDeadEndConnections(procs)
+	wf.snapshotRunEdges()
+	addRunningWorkflow(wf)
+	defer removeRunningWorkflow(wf)
 
-	if !wf.readyToRun(procs) {
-		wf.Fail("Workflow not ready to run, due to previously reported errors, so exiting.")
+	if errs := wf.validate(procs); len(errs) > 0 {
+		for _, err := range errs {
+			Error.Printf("[Workflow:%s] %s\n", wf.Name(), err)
+		}
+		wf.Failf("Workflow not ready to run, due to %d previously reported error(s), so exiting.", len(errs))
 	}
 
-	for _, proc := range procs {
-		Debug.Printf(wf.name+": Starting process (%s) in new go-routine", proc.Name())
-		go proc.Run()
+	logEvent(Audit, LogEntry{Event: "workflow_started", Workflow: wf.Name()}, fmt.Sprintf("[Workflow:%s] Starting workflow (Writing log to %s)\n", wf.Name(), wf.logFile))
+	wf.tasksLock.Lock()
+	wf.startTime = time.Now()
+	wf.runID = newRunID()
+	wf.historyWritten = false
+	wf.tasksLock.Unlock()
+
+	var progress *progressDisplay
+	if wf.ShowProgress {
+		progress = newProgressDisplay(wf, os.Stdout, isTerminal(os.Stdout))
+		progress.start()
 	}
 
-	Debug.Printf("%s: Starting driver process (%s) in main go-routine", wf.name, wf.driver.Name())
-	wf.Auditf("Starting workflow (Writing log to %s)", wf.logFile)
-	wf.driver.Run()
-	wf.Auditf("Finished workflow (Log written to %s)", wf.logFile)
-}
-
-func (wf *Workflow) readyToRun(procs map[string]WorkflowProcess) bool {
-	if len(procs) == 0 {
-		Error.Println(wf.name + ": The workflow is empty. Did you forget to add the processes to it?")
-		return false
-	}
-	if wf.sink == nil {
-		Error.Println(wf.name + ": sink is nil!")
-		return false
-	}
+	// Processes without out-ports can't be connected to the sink, so all
+	// processes are waited for, together with the sink, to drive the
+	// workflow. Waiting for all processes also makes sure that their
+	// OnProcessDone hooks have been called before the workflow is done.
+	running := &sync.WaitGroup{}
 	for _, proc := range procs {
-		if !proc.Ready() {
-			Error.Println(wf.name + ": Not everything connected. Workflow shutting down.")
-			return false
+		if isTerminalProc(proc) {
+			Debug.Printf(wf.name+": Starting driver process (%s) in new go-routine", proc.Name())
+		} else {
+			Debug.Printf(wf.name+": Starting process (%s) in new go-routine", proc.Name())
 		}
+		running.Add(1)
+		go func(proc WorkflowProcess) {
+			defer running.Done()
+			wf.runProc(proc)
+		}(proc)
+	}
+
+	Debug.Printf("%s: Starting sink (%s) in main go-routine", wf.name, wf.sink.Name())
+	wf.sink.Run()
+	running.Wait()
+	if progress != nil {
+		progress.stop()
 	}
-	return true
+	wf.writeStatusGraph()
+	wf.writeTraceFile()
+	wf.printSummary()
+	wf.appendHistory(RunStatusFinished)
+	logEvent(Audit, LogEntry{Event: "workflow_finished", Workflow: wf.Name(), DurationS: time.Since(wf.startTime).Seconds()}, fmt.Sprintf("[Workflow:%s] Finished workflow (Log written to %s)\n", wf.Name(), wf.logFile))
+	wf.workflowDone()
 }
 
 // reconnectDeadEndConnections disonnects connections to processes which are
@@ -344,9 +488,11 @@ func (wf *Workflow) readyToRun(procs map[string]WorkflowProcess) bool {
 // supposed to be run gets disconnected, its out-port(s) will be connected to
 // the sink instead, to make sure it is properly executed.
 func (wf *Workflow) reconnectDeadEndConnections(procs map[string]WorkflowProcess) {
-	foundNewDriverProc := false
-
 	for _, proc := range procs {
+		if sw, ok := proc.(*SubWorkflow); ok {
+			sw.connectDanglingOutPorts()
+		}
+
 		// OutPorts
 		for _, opt := range proc.OutPorts() {
 			for iptName, ipt := range opt.RemotePorts {
@@ -382,43 +528,84 @@ func (wf *Workflow) reconnectDeadEndConnections(procs map[string]WorkflowProcess
 				wf.sink.FromParam(pop)
 			}
 		}
-
-		if len(proc.OutPorts()) == 0 && len(proc.OutParamPorts()) == 0 {
-			if foundNewDriverProc {
-				wf.Failf("Found more than one process without out-ports nor out-param ports. Cannot use both as drivers (One of them being '%s'). Adapt your workflow accordingly.", proc.Name())
-			}
-			foundNewDriverProc = true
-			wf.driver = proc
-		}
 	}
+}
 
-	if foundNewDriverProc && len(procs) > 1 { // Allow for a workflow with a single process
-		// A process can't both be the driver and be included in the main procs
-		// map, so if we have an alerative driver, it should not be in the main
-		// procs map
-		delete(wf.procs, wf.driver.Name())
-	}
+// isTerminalProc returns true if proc has neither out-ports nor out-param
+// ports, meaning that it can not be connected to the sink, but has to be run
+// as a driver of the workflow, alongside the sink
+func isTerminalProc(proc WorkflowProcess) bool {
+	return len(proc.OutPorts()) == 0 && len(proc.OutParamPorts()) == 0
 }
 
 // upstreamProcsForProc returns all processes it is connected to, either
 // directly or indirectly, via its in-ports and param-in-ports
 func upstreamProcsForProc(proc WorkflowProcess) map[string]WorkflowProcess {
 	procs := map[string]WorkflowProcess{}
-	for _, inp := range proc.InPorts() {
-		for _, rpt := range inp.RemotePorts {
-			procs[rpt.Process().Name()] = rpt.Process()
-			mergeWFMaps(procs, upstreamProcsForProc(rpt.Process()))
+	for _, upstreamProc := range upstreamProcsSorted(proc) {
+		if _, ok := procs[upstreamProc.Name()]; ok {
+			continue
+		}
+		procs[upstreamProc.Name()] = upstreamProc
+		if upstreamProc != proc {
+			mergeWFMaps(procs, upstreamProcsForProc(upstreamProc))
 		}
 	}
-	for _, pip := range proc.InParamPorts() {
-		for _, rpp := range pip.RemotePorts {
-			procs[rpp.Process().Name()] = rpp.Process()
-			mergeWFMaps(procs, upstreamProcsForProc(rpp.Process()))
+	return procs
+}
+
+// downstreamProcsForProc returns all processes it is connected to, either
+// directly or indirectly, via its out-ports and param-out-ports, following
+// connections into and out of sub-workflows
+func downstreamProcsForProc(proc WorkflowProcess) map[string]WorkflowProcess {
+	procs := map[string]WorkflowProcess{}
+	var visit func(proc WorkflowProcess)
+	visit = func(proc WorkflowProcess) {
+		if sw, ok := proc.(*SubWorkflow); ok {
+			for _, inner := range sw.ProcsSorted() {
+				visit(inner)
+			}
+		}
+		for _, opt := range proc.OutPorts() {
+			for _, rpt := range opt.RemotePorts {
+				for _, ipt := range resolveInPorts(rpt) {
+					if _, ok := procs[ipt.Process().Name()]; !ok && ipt.Process() != proc {
+						procs[ipt.Process().Name()] = ipt.Process()
+						visit(ipt.Process())
+					}
+				}
+			}
+		}
+		for _, pop := range proc.OutParamPorts() {
+			for _, rpp := range pop.RemotePorts {
+				for _, pip := range resolveInParamPorts(rpp) {
+					if _, ok := procs[pip.Process().Name()]; !ok && pip.Process() != proc {
+						procs[pip.Process().Name()] = pip.Process()
+						visit(pip.Process())
+					}
+				}
+			}
 		}
 	}
+	visit(proc)
 	return procs
 }
 
+// setForceRerun sets ForceRerun on proc, or on all the inner processes if
+// proc is a sub-workflow
+func setForceRerun(proc WorkflowProcess) {
+	switch p := proc.(type) {
+	case *Process:
+		p.ForceRerun = true
+	case *SubWorkflow:
+		for _, inner := range p.ProcsSorted() {
+			setForceRerun(inner)
+		}
+	default:
+		Debug.Printf("[Process:%s] Not a process created with NewProc, so can not force it to re-run\n", proc.Name())
+	}
+}
+
 func mergeWFMaps(a map[string]WorkflowProcess, b map[string]WorkflowProcess) map[string]WorkflowProcess {
 	for k, v := range b {
 		a[k] = v
@@ -437,3 +624,42 @@ func (wf *Workflow) Failf(msg string, parts ...interface{}) {
 func (wf *Workflow) Fail(msg interface{}) {
 	Failf("[Workflow:%s] %s", wf.Name(), msg)
 }
+
+// runningWorkflows contains the workflows that are currently running, so that
+// their status graph, trace and history can be written when the program exits
+// because of a failure, whichever part of the code the failure came from
+var (
+	runningWorkflows     = map[*Workflow]bool{}
+	runningWorkflowsLock sync.Mutex
+)
+
+func addRunningWorkflow(wf *Workflow) {
+	runningWorkflowsLock.Lock()
+	defer runningWorkflowsLock.Unlock()
+	runningWorkflows[wf] = true
+}
+
+func removeRunningWorkflow(wf *Workflow) {
+	runningWorkflowsLock.Lock()
+	defer runningWorkflowsLock.Unlock()
+	delete(runningWorkflows, wf)
+}
+
+// recordFailedRuns writes the status graph and trace of all running
+// workflows, and appends a record of their runs, with the status
+// RunStatusFailed, to the run history. It is called before exiting because of
+// a failure.
+func recordFailedRuns() {
+	runningWorkflowsLock.Lock()
+	wfs := []*Workflow{}
+	for wf := range runningWorkflows {
+		wfs = append(wfs, wf)
+	}
+	runningWorkflowsLock.Unlock()
+
+	for _, wf := range wfs {
+		wf.writeStatusGraph()
+		wf.writeTraceFile()
+		wf.appendHistory(RunStatusFailed)
+	}
+}
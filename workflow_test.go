@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -169,6 +170,67 @@ func getWorkflowForTestRunToProc(wfName string) *Workflow {
 	return wf
 }
 
+func TestRunFrom(t *testing.T) {
+	initTestLogs()
+
+	getWorkflow := func(wfName string, barWord string) *Workflow {
+		wf := NewWorkflow(wfName, 4)
+		foo := wf.NewProc("foo", "echo foo > {o:out}")
+		foo.SetOut("out", ".tmp/runfrom_foo.txt")
+		bar := wf.NewProc("bar", "sed 's/foo/"+barWord+"/' {i:in} > {o:out}")
+		bar.SetOut("out", "{i:in|%.txt}.bar.txt")
+		bar.In("in").From(foo.Out("out"))
+		cat := wf.NewProc("cat", "cat {i:in} > {o:out}")
+		cat.SetOut("out", "{i:in|%.txt}.cat.txt")
+		cat.In("in").From(bar.Out("out"))
+		return wf
+	}
+
+	wf := getWorkflow("TestRunFromWF_A", "bbr")
+	wf.Run()
+
+	// Fix the "bug" in the bar process, and re-run from it
+	wf = getWorkflow("TestRunFromWF_B", "bar")
+	wf.RunFrom("bar")
+
+	assertEqualValues(t, 1, wf.Proc("foo").(*Process).Stats().TasksSkipped)
+	assertEqualValues(t, 1, wf.Proc("bar").(*Process).Stats().TasksRun)
+	assertEqualValues(t, 1, wf.Proc("cat").(*Process).Stats().TasksRun)
+	dat, err := ioutil.ReadFile(".tmp/runfrom_foo.bar.cat.txt")
+	assertNil(t, err)
+	assertEqualValues(t, "bar\n", string(dat))
+
+	// Old outputs, and their audit files, should have been moved aside
+	for _, ptn := range []string{
+		".tmp/runfrom_foo.bar.txt.old.*",
+		".tmp/runfrom_foo.bar.txt.audit.json.old.*",
+		".tmp/runfrom_foo.bar.cat.txt.old.*",
+	} {
+		matches, _ := filepath.Glob(ptn)
+		if len(matches) != 1 {
+			t.Errorf("Expected one file moved aside matching %s, but found: %v", ptn, matches)
+		}
+	}
+	matches, _ := filepath.Glob(".tmp/runfrom_foo.txt.old.*")
+	if len(matches) != 0 {
+		t.Errorf("Output of process upstream of the re-run ones should not be moved aside, but found: %v", matches)
+	}
+
+	// Re-run again, deleting the old outputs instead
+	cleanFilePatterns(".tmp/runfrom_*.old.*")
+	wf = getWorkflow("TestRunFromWF_C", "bar")
+	wf.DeleteOldOutputs = true
+	wf.RunFrom("cat")
+	assertEqualValues(t, 1, wf.Proc("bar").(*Process).Stats().TasksSkipped)
+	assertEqualValues(t, 1, wf.Proc("cat").(*Process).Stats().TasksRun)
+	matches, _ = filepath.Glob(".tmp/runfrom_*.old.*")
+	if len(matches) != 0 {
+		t.Errorf("Old outputs should have been deleted, but found: %v", matches)
+	}
+
+	cleanFilePatterns(".tmp/runfrom_*")
+}
+
 func TestBasicRun(t *testing.T) {
 	initTestLogs()
 	wf := NewWorkflow("TestBasicRunWf", 16)
@@ -757,6 +819,43 @@ func (p *ParamSource) Run() {
 	}
 }
 
+// --------------------------------------------------------------------------------
+// ParamCounter helper process
+// --------------------------------------------------------------------------------
+
+// ParamCounter receives all parameters on its in-port, and then sends the
+// number of parameters received on its out-port
+type ParamCounter struct {
+	BaseProcess
+}
+
+// NewParamCounter returns a new ParamCounter
+func NewParamCounter(wf *Workflow, name string) *ParamCounter {
+	p := &ParamCounter{
+		BaseProcess: NewBaseProcess(wf, name),
+	}
+	p.InitInParamPort(p, "in")
+	p.InitOutParamPort(p, "out")
+	wf.AddProc(p)
+	return p
+}
+
+// In returns the in-port, on which the parameters to count are received
+func (p *ParamCounter) In() *InParamPort { return p.InParamPort("in") }
+
+// Out returns the out-port, on which the number of parameters is sent
+func (p *ParamCounter) Out() *OutParamPort { return p.OutParamPort("out") }
+
+// Run runs the process
+func (p *ParamCounter) Run() {
+	defer p.CloseAllOutPorts()
+	count := 0
+	for range p.In().Chan {
+		count++
+	}
+	p.Out().Send(strconv.Itoa(count))
+}
+
 // --------------------------------
 // BogusProcess helper process
 // --------------------------------